@@ -0,0 +1,36 @@
+// Command fakecrud runs a local crudcrud-compatible API for development.
+//
+// Point the handlers at it with:
+//
+//	UNICORN_API_ENDPOINT=http://localhost:8000/api UNICORN_API_KEY=local
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/brianterry/unicorn-maker/go/fakecrud"
+)
+
+func main() {
+	addr := flag.String("addr", ":8000", "address to listen on")
+	file := flag.String("file", "", "JSON file to persist documents to (memory only if empty)")
+	flag.Parse()
+
+	var (
+		server *fakecrud.Server
+		err    error
+	)
+	if *file == "" {
+		server = fakecrud.NewServer()
+	} else {
+		server, err = fakecrud.NewFileServer(*file)
+		if err != nil {
+			log.Fatalf("Unable to load %s: %v", *file, err)
+		}
+	}
+
+	log.Printf("fakecrud listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
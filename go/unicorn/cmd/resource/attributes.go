@@ -0,0 +1,86 @@
+package resource
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+)
+
+// arnService is the service part of the unicorn ARNs.
+const arnService = "brianterry-unicorn-maker"
+
+// arn returns the ARN-like identifier of the unicorn, built from the
+// region and account it was created in. Unicorns created outside of
+// CloudFormation have neither, like S3 bucket ARNs.
+func arn(u *Unicorn) string {
+	partition := endpoints.AwsPartitionID
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), u.Region); ok {
+		partition = p.ID()
+	}
+	return fmt.Sprintf("arn:%s:%s:%s:%s:unicorn/%s", partition, arnService, u.Region, u.AccountID, u.ID)
+}
+
+// timestamp formats t the way CreatedAt and UpdatedAt are stored.
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// stampCreate sets the computed attributes of a unicorn about to be created.
+func stampCreate(u *Unicorn, req handler.Request, now time.Time) {
+	u.CreatedAt = timestamp(now)
+	u.UpdatedAt = u.CreatedAt
+	u.Version = 1
+	u.Region = req.RequestContext.Region
+	u.AccountID = req.RequestContext.AccountID
+	u.StackID = req.RequestContext.StackID
+	u.LogicalResourceID = req.LogicalResourceID
+}
+
+// stampUpdate sets the computed attributes of a unicorn about to replace existing.
+func stampUpdate(u *Unicorn, existing *Unicorn, now time.Time) {
+	u.CreatedAt = existing.CreatedAt
+	u.UpdatedAt = timestamp(now)
+	u.Version = existing.Version + 1
+	u.Region = existing.Region
+	u.AccountID = existing.AccountID
+	u.StackID = existing.StackID
+	u.LogicalResourceID = existing.LogicalResourceID
+}
+
+// copyReadOnly copies the read-only properties of prevModel that
+// currentModel lacks, so an update that skips the backend still
+// returns them.
+func copyReadOnly(prevModel *Model, currentModel *Model) {
+	if prevModel == nil {
+		return
+	}
+	if currentModel.CreatedAt == nil {
+		currentModel.CreatedAt = prevModel.CreatedAt
+	}
+	if currentModel.UpdatedAt == nil {
+		currentModel.UpdatedAt = prevModel.UpdatedAt
+	}
+	if currentModel.Arn == nil {
+		currentModel.Arn = prevModel.Arn
+	}
+	if currentModel.Version == nil {
+		currentModel.Version = prevModel.Version
+	}
+}
+
+// readOnly sets the read-only properties of m from the stored unicorn.
+func readOnly(m *Model, u *Unicorn) {
+	m.Arn = aws.String(arn(u))
+	if u.CreatedAt != "" {
+		m.CreatedAt = aws.String(u.CreatedAt)
+	}
+	if u.UpdatedAt != "" {
+		m.UpdatedAt = aws.String(u.UpdatedAt)
+	}
+	if u.Version != 0 {
+		m.Version = aws.Int(u.Version)
+	}
+}
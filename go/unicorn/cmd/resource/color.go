@@ -0,0 +1,41 @@
+package resource
+
+import (
+	"strings"
+)
+
+// palette returns the named colors allowed by the enum in the Color
+// property of the resource schema, so the two cannot drift apart.
+func palette() []string {
+	color := resourceSchema.Properties["Color"]
+	if color == nil {
+		return nil
+	}
+	var names []string
+	for _, alt := range color.AnyOf {
+		for _, v := range alt.Enum {
+			if name, ok := v.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// normalizeColor returns the canonical form of a color: palette names
+// are matched case-insensitively and spelled as in the schema, and hex
+// codes are upper-cased, e.g. " pink " becomes "Pink" and "#ff00aa"
+// becomes "#FF00AA". Anything else is returned trimmed, for the schema
+// validation to reject.
+func normalizeColor(color string) string {
+	color = strings.TrimSpace(color)
+	if strings.HasPrefix(color, "#") {
+		return strings.ToUpper(color)
+	}
+	for _, name := range palette() {
+		if strings.EqualFold(color, name) {
+			return name
+		}
+	}
+	return color
+}
@@ -0,0 +1,33 @@
+package resource
+
+import (
+	"fmt"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// expectedVersion returns the version of the unicorn the update was made
+// against: the one CloudFormation passes back in the current model, or
+// the one it last read. It returns 0 when neither has a version, for
+// unicorns created before versions were recorded.
+func expectedVersion(prevModel *Model, currentModel *Model) int {
+	if currentModel.Version != nil {
+		return *currentModel.Version
+	}
+	if prevModel != nil && prevModel.Version != nil {
+		return *prevModel.Version
+	}
+	return 0
+}
+
+// conflict returns the failed event for an update made against a version
+// of the unicorn that is no longer the stored one.
+func conflict(uid string, expected, found int) handler.ProgressEvent {
+	return handler.ProgressEvent{
+		OperationStatus:  handler.Failed,
+		HandlerErrorCode: cloudformation.HandlerErrorCodeResourceConflict,
+		Message: fmt.Sprintf("Unicorn %s was changed by someone else: expected version %d, found version %d",
+			uid, expected, found),
+	}
+}
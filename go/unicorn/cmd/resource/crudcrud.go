@@ -0,0 +1,384 @@
+package resource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CrudCrudStore is a UnicornStore backed by the crudcrud REST API.
+type CrudCrudStore struct {
+	// Endpoint is the URL of the crudcrud collection,
+	// e.g. https://crudcrud.com/api/<Your API ID>/unicorns
+	Endpoint string
+	// Client is the HTTP client used to make requests.
+	Client *http.Client
+	// Retry controls timeouts and retries.
+	Retry RetryPolicy
+	// Deadline is when the handler invocation runs out of time.
+	// No attempt or retry is started that would run past it.
+	// The zero value means no deadline.
+	Deadline time.Time
+	// Log receives an entry for every request, with its latency.
+	// Nil disables logging.
+	Log *Logger
+	// Action is the handler action the requests are made for.
+	// It is the dimension of the request metrics.
+	Action string
+	// Metrics receives the metrics of every request, in CloudWatch
+	// embedded metric format. Nil disables metrics.
+	Metrics io.Writer
+	// Span is the parent of the spans traced for each request.
+	// Nil disables tracing.
+	Span *Span
+}
+
+// NewCrudCrudStore returns a CrudCrudStore for the given collection endpoint.
+func NewCrudCrudStore(endpoint string) *CrudCrudStore {
+	return &CrudCrudStore{
+		Endpoint: endpoint,
+		Client:   httpClient,
+		Retry:    DefaultRetryPolicy,
+	}
+}
+
+// RequestInput represents the input when making the HTTP request.
+type RequestInput struct {
+	// Method is the the HTTP request method.
+	Method string
+	// URL is the request URL
+	URL string
+	// Body is the body of the request.
+	// It is kept as bytes so it can be sent again on retry.
+	Body []byte
+}
+
+// Create POSTs the unicorn to the collection.
+func (s *CrudCrudStore) Create(u *Unicorn) (*Unicorn, error) {
+	body, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+	created := Unicorn{}
+	err = s.makeRequest(&RequestInput{
+		Method: "POST",
+		URL:    s.Endpoint,
+		Body:   body,
+	}, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Get fetches a single unicorn by ID.
+func (s *CrudCrudStore) Get(id string) (*Unicorn, error) {
+	u := Unicorn{}
+	err := s.makeRequest(&RequestInput{
+		Method: "GET",
+		URL:    s.Endpoint + "/" + id,
+	}, &u)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Put replaces the unicorn with the given ID.
+func (s *CrudCrudStore) Put(id string, u *Unicorn) error {
+	// crudcrud rejects bodies that carry the _id field.
+	put := *u
+	put.ID = ""
+	body, err := json.Marshal(&put)
+	if err != nil {
+		return err
+	}
+	return s.makeRequest(&RequestInput{
+		Method: "PUT",
+		URL:    s.Endpoint + "/" + id,
+		Body:   body,
+	}, nil)
+}
+
+// Delete removes the unicorn with the given ID.
+func (s *CrudCrudStore) Delete(id string) error {
+	return s.makeRequest(&RequestInput{
+		Method: "DELETE",
+		URL:    s.Endpoint + "/" + id,
+	}, nil)
+}
+
+// List returns a page of the collection. crudcrud has no server side
+// paging, so the whole collection is fetched and paged here.
+func (s *CrudCrudStore) List(token string, limit int) ([]Unicorn, string, error) {
+	unicorns, err := s.scan()
+	if err != nil {
+		return nil, "", err
+	}
+	return page(unicorns, token, limit)
+}
+
+// scan fetches the whole collection with a single request.
+func (s *CrudCrudStore) scan() ([]Unicorn, error) {
+	var unicorns []Unicorn
+	err := s.makeRequest(&RequestInput{
+		Method: "GET",
+		URL:    s.Endpoint,
+	}, &unicorns)
+	if err != nil {
+		return nil, err
+	}
+	return unicorns, nil
+}
+
+// makeRequest sends the request, retrying with jittered exponential backoff
+// while the failure is retryable and time remains, and, if out is not nil,
+// decodes the JSON response body into it.
+func (s *CrudCrudStore) makeRequest(input *RequestInput, out interface{}) (err error) {
+	var (
+		last    *response
+		retries int
+	)
+	defer func(started time.Time) {
+		s.recordRequest(input, last, retries, time.Since(started), err)
+	}(time.Now())
+
+	for retry := 0; ; retry++ {
+		started := time.Now()
+		span := s.startRequestSpan(input, retry)
+		resp, err := s.do(input, span)
+		s.logRequest(input, resp, err, retry, time.Since(started))
+		endRequestSpan(span, resp, err)
+		last, retries = resp, retry
+		if serr, ok := err.(*StoreError); ok {
+			return serr
+		}
+		retryable := false
+		if err != nil {
+			retryable = retryableError(input.Method, err)
+		} else {
+			retryable = retryableStatus(input.Method, resp.StatusCode)
+		}
+		if !retryable {
+			if err != nil {
+				return &StoreError{
+					Code:    cloudformation.HandlerErrorCodeNetworkFailure,
+					Message: err.Error(),
+				}
+			}
+			return decodeResponse(resp, out)
+		}
+
+		delay := s.Retry.backoff(retry)
+		if retry >= s.Retry.MaxRetries || !s.hasTime(delay) {
+			return exhausted(input, resp, err, retry+1)
+		}
+		time.Sleep(delay)
+	}
+}
+
+// response is a backend response with its body read into memory.
+type response struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+// do makes a single attempt, bounded by the request timeout and the deadline.
+func (s *CrudCrudStore) do(input *RequestInput, span *Span) (*response, error) {
+	ctx := context.Background()
+	if s.Retry.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Retry.RequestTimeout)
+		defer cancel()
+	}
+	if !s.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, s.Deadline)
+		defer cancel()
+	}
+
+	// Create request
+	var body io.Reader
+	if input.Body != nil {
+		body = bytes.NewReader(input.Body)
+	}
+	re, err := http.NewRequest(input.Method, input.URL, body)
+	if err != nil {
+		return nil, &StoreError{
+			Code:    cloudformation.HandlerErrorCodeInvalidRequest,
+			Message: err.Error(),
+		}
+	}
+	re = re.WithContext(ctx)
+
+	// If the body is not nil, we set the content header
+	if input.Body != nil {
+		re.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	// Propagate the trace to the backend.
+	span.inject(re.Header)
+
+	// Fetch Request
+	resp, err := s.Client.Do(re)
+	if uerr, ok := err.(*url.Error); ok {
+		// Drop the URL from the error, it contains the API key.
+		err = uerr.Err
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// Read the body before the context is cancelled.
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       b,
+	}, nil
+}
+
+// hasTime reports whether there is time to wait delay and make another attempt.
+func (s *CrudCrudStore) hasTime(delay time.Duration) bool {
+	if s.Deadline.IsZero() {
+		return true
+	}
+	return time.Until(s.Deadline) > delay
+}
+
+// exhausted maps the last failure of a request that ran out of retries.
+func exhausted(input *RequestInput, resp *response, err error, attempts int) error {
+	if err != nil {
+		return &StoreError{
+			Code:    cloudformation.HandlerErrorCodeNetworkFailure,
+			Message: fmt.Sprintf("%s request failed after %s: %v", input.Method, countAttempts(attempts), err),
+		}
+	}
+	serr := statusError(resp)
+	serr.Message = fmt.Sprintf("%s request failed after %s: %s", input.Method, countAttempts(attempts), serr.Message)
+	return serr
+}
+
+func countAttempts(n int) string {
+	if n == 1 {
+		return "1 attempt"
+	}
+	return fmt.Sprintf("%d attempts", n)
+}
+
+// logRequest logs a single attempt of a backend request. The path is
+// logged relative to the endpoint, which contains the API key.
+func (s *CrudCrudStore) logRequest(input *RequestInput, resp *response, err error, retry int, latency time.Duration) {
+	path := strings.TrimPrefix(input.URL, s.Endpoint)
+	if path == "" {
+		path = "/"
+	}
+	kv := []interface{}{
+		"method", input.Method,
+		"path", path,
+		"retry", retry,
+		"latencyMs", milliseconds(latency),
+	}
+	if err != nil {
+		s.Log.Error("Backend request failed", append(kv, "error", err)...)
+		return
+	}
+	s.Log.Info("Backend request", append(kv, "statusCode", resp.StatusCode)...)
+}
+
+// startRequestSpan starts the span of a single attempt of a backend request.
+// The URL attribute is redacted, as it contains the API key.
+func (s *CrudCrudStore) startRequestSpan(input *RequestInput, retry int) *Span {
+	span := s.Span.startChild(input.Method, trace.SpanKindClient)
+	span.SetAttribute("http.request.method", input.Method)
+	span.SetAttribute("url.full", input.URL)
+	if retry > 0 {
+		span.SetAttribute("http.request.resend_count", retry)
+	}
+	return span
+}
+
+// endRequestSpan ends the span of a backend request attempt with its outcome.
+func endRequestSpan(span *Span, resp *response, err error) {
+	switch {
+	case err != nil:
+		span.SetStatus(false, err.Error())
+	case resp.StatusCode >= 400:
+		span.SetAttribute("http.response.status_code", resp.StatusCode)
+		span.SetStatus(false, resp.Status)
+	default:
+		span.SetAttribute("http.response.status_code", resp.StatusCode)
+	}
+	span.End()
+}
+
+// statusCodes maps backend HTTP statuses to handler error codes.
+// 5xx statuses not listed map to ServiceInternalError, anything
+// else that is not a success to GeneralServiceException.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:      cloudformation.HandlerErrorCodeInvalidRequest,
+	http.StatusUnauthorized:    cloudformation.HandlerErrorCodeInvalidCredentials,
+	http.StatusForbidden:       cloudformation.HandlerErrorCodeAccessDenied,
+	http.StatusNotFound:        cloudformation.HandlerErrorCodeNotFound,
+	http.StatusConflict:        cloudformation.HandlerErrorCodeAlreadyExists,
+	http.StatusTooManyRequests: cloudformation.HandlerErrorCodeThrottling,
+}
+
+// maxMessageBody is how much of a response body is copied into an error message.
+const maxMessageBody = 512
+
+// statusError translates a non-success response into a StoreError
+// whose message carries the response body.
+func statusError(resp *response) *StoreError {
+	code, ok := statusCodes[resp.StatusCode]
+	switch {
+	case ok:
+	case resp.StatusCode >= 500:
+		code = cloudformation.HandlerErrorCodeServiceInternalError
+	default:
+		code = cloudformation.HandlerErrorCodeGeneralServiceException
+	}
+
+	msg := "Backend returned " + resp.Status
+	if body := strings.TrimSpace(string(resp.Body)); body != "" {
+		if len(body) > maxMessageBody {
+			body = body[:maxMessageBody] + "..."
+		}
+		msg += ": " + body
+	}
+	return &StoreError{
+		Code:    code,
+		Message: msg,
+	}
+}
+
+// decodeResponse maps the response status and, if out is not nil,
+// decodes the JSON body into it.
+func decodeResponse(resp *response, out interface{}) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Body, out); err != nil {
+		return &StoreError{
+			Code:    cloudformation.HandlerErrorCodeGeneralServiceException,
+			Message: "Unable to decode backend response: " + err.Error(),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package resource
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestStatusError(t *testing.T) {
+	long := strings.Repeat("x", maxMessageBody+100)
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		code    string
+		message string
+	}{
+		{
+			name:    "400",
+			status:  http.StatusBadRequest,
+			body:    `{"error":"bad unicorn"}`,
+			code:    cloudformation.HandlerErrorCodeInvalidRequest,
+			message: `Backend returned 400 Bad Request: {"error":"bad unicorn"}`,
+		},
+		{
+			name:    "401",
+			status:  http.StatusUnauthorized,
+			code:    cloudformation.HandlerErrorCodeInvalidCredentials,
+			message: "Backend returned 401 Unauthorized",
+		},
+		{
+			name:    "403",
+			status:  http.StatusForbidden,
+			body:    "  expired key\n",
+			code:    cloudformation.HandlerErrorCodeAccessDenied,
+			message: "Backend returned 403 Forbidden: expired key",
+		},
+		{
+			name:    "404",
+			status:  http.StatusNotFound,
+			code:    cloudformation.HandlerErrorCodeNotFound,
+			message: "Backend returned 404 Not Found",
+		},
+		{
+			name:    "409",
+			status:  http.StatusConflict,
+			body:    "exists",
+			code:    cloudformation.HandlerErrorCodeAlreadyExists,
+			message: "Backend returned 409 Conflict: exists",
+		},
+		{
+			name:    "429",
+			status:  http.StatusTooManyRequests,
+			code:    cloudformation.HandlerErrorCodeThrottling,
+			message: "Backend returned 429 Too Many Requests",
+		},
+		{
+			name:    "500",
+			status:  http.StatusInternalServerError,
+			body:    "oops",
+			code:    cloudformation.HandlerErrorCodeServiceInternalError,
+			message: "Backend returned 500 Internal Server Error: oops",
+		},
+		{
+			name:    "502",
+			status:  http.StatusBadGateway,
+			code:    cloudformation.HandlerErrorCodeServiceInternalError,
+			message: "Backend returned 502 Bad Gateway",
+		},
+		{
+			name:    "3xx",
+			status:  http.StatusNotModified,
+			code:    cloudformation.HandlerErrorCodeGeneralServiceException,
+			message: "Backend returned 304 Not Modified",
+		},
+		{
+			name:    "long body",
+			status:  http.StatusBadRequest,
+			body:    long,
+			code:    cloudformation.HandlerErrorCodeInvalidRequest,
+			message: "Backend returned 400 Bad Request: " + long[:maxMessageBody] + "...",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			store := NewCrudCrudStore(srv.URL + "/unicorns")
+			store.Retry.MaxRetries = 0
+
+			_, err := store.Get("1")
+			var serr *StoreError
+			if !errors.As(err, &serr) {
+				t.Fatalf("Get() error = %v, want a StoreError", err)
+			}
+			// Retryable statuses also report the number of attempts.
+			want := tt.message
+			if retryableStatus(http.MethodGet, tt.status) {
+				want = "GET request failed after 1 attempt: " + want
+			}
+			if serr.Code != tt.code || serr.Message != want {
+				t.Errorf("Get() error = %s %q, want %s %q", serr.Code, serr.Message, tt.code, want)
+			}
+		})
+	}
+}
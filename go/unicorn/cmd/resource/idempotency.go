@@ -0,0 +1,102 @@
+package resource
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+)
+
+// scanPageSize is the page size used when searching the whole collection
+// of a store that cannot scan it.
+const scanPageSize = 1000
+
+// idempotencyKey derives the key that identifies a Create across retries.
+//
+// The cfn plugin does not pass the client request token to the handlers,
+// so the key is built from the stack, the logical resource ID and the
+// desired properties. A retried Create has all three in common, while a
+// replacement of the same logical resource has different properties.
+// It returns "" when the request carries no stack or logical ID.
+func idempotencyKey(req handler.Request, model *Model) string {
+	if req.RequestContext.StackID == "" && req.LogicalResourceID == "" {
+		return ""
+	}
+	props, _ := json.Marshal(marshal(model))
+	h := sha256.New()
+	h.Write([]byte(req.RequestContext.StackID))
+	h.Write([]byte{0})
+	h.Write([]byte(req.LogicalResourceID))
+	h.Write([]byte{0})
+	h.Write(props)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// findCreated returns the unicorn stored by an earlier attempt of the
+// Create with the given idempotency key, or nil if there is none.
+// Names identify unicorns too, so it fails with AlreadyExists when
+// another unicorn has the name, unless that unicorn was created by the
+// same logical resource: CloudFormation creates the replacement of a
+// resource before it deletes the original, so both briefly share the name.
+// Both are looked up in a single scan.
+func findCreated(store UnicornStore, req handler.Request, key string, name string) (*Unicorn, error) {
+	taken := false
+	u, err := findUnicorn(store, func(u *Unicorn) bool {
+		if key != "" && u.IdempotencyKey == key {
+			return true
+		}
+		taken = taken || (u.Name == name && !sameResource(u, req))
+		return false
+	})
+	if err != nil || u != nil {
+		return u, err
+	}
+	if taken {
+		return nil, nameTaken(name)
+	}
+	return nil, nil
+}
+
+// sameResource reports whether u was created by the logical resource the request is for.
+func sameResource(u *Unicorn, req handler.Request) bool {
+	return u.StackID != "" && u.StackID == req.RequestContext.StackID &&
+		u.LogicalResourceID == req.LogicalResourceID
+}
+
+// findUnicorn searches the whole collection and returns the first
+// unicorn for which match returns true, or nil. Stores that can scan
+// are searched with a single request; the others are paged through.
+func findUnicorn(store UnicornStore, match func(*Unicorn) bool) (*Unicorn, error) {
+	if sc, ok := store.(scanner); ok {
+		unicorns, err := sc.scan()
+		if err != nil {
+			return nil, err
+		}
+		return first(unicorns, match), nil
+	}
+	token := ""
+	for {
+		unicorns, next, err := store.List(token, scanPageSize)
+		if err != nil {
+			return nil, err
+		}
+		if u := first(unicorns, match); u != nil {
+			return u, nil
+		}
+		if next == "" {
+			return nil, nil
+		}
+		token = next
+	}
+}
+
+// first returns the first of unicorns for which match returns true, or nil.
+func first(unicorns []Unicorn, match func(*Unicorn) bool) *Unicorn {
+	for i := range unicorns {
+		if match(&unicorns[i]) {
+			return &unicorns[i]
+		}
+	}
+	return nil
+}
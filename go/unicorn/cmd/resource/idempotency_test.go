@@ -0,0 +1,118 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/brianterry/unicorn-maker/go/fakecrud"
+)
+
+func TestCreateReplacement(t *testing.T) {
+	store := useMemoryStore(t)
+	original := &Model{Name: aws.String("Sparkles"), Color: aws.String("Pink"), Species: aws.String("Unicorn")}
+	event, err := Create(newRequest(), &Model{}, original)
+	checkEvent(t, event, err, handler.Success, "")
+	originalUID := aws.StringValue(event.ResourceModel.(*Model).UID)
+
+	// A change to Species makes CloudFormation create the replacement
+	// with the same name and logical ID before it deletes the original.
+	replacement := &Model{Name: aws.String("Sparkles"), Color: aws.String("Pink"), Species: aws.String("Pegasus")}
+	event, err = Create(newRequest(), &Model{}, replacement)
+	checkEvent(t, event, err, handler.Success, "")
+	if uid := aws.StringValue(event.ResourceModel.(*Model).UID); uid == originalUID {
+		t.Fatalf("replacement has the UID of the original, %s", uid)
+	}
+	event, err = Delete(newRequest(), &Model{}, &Model{UID: aws.String(originalUID)})
+	checkEvent(t, event, err, handler.Success, "")
+	if unicorns, _, _ := store.List("", 10); len(unicorns) != 1 || unicorns[0].Species != "Pegasus" {
+		t.Errorf("stored %+v, want only the replacement", unicorns)
+	}
+
+	// Another logical resource still cannot take the name.
+	req := newRequest()
+	req.LogicalResourceID = "OtherUnicorn"
+	event, err = Create(req, &Model{}, &Model{Name: aws.String("Sparkles"), Color: aws.String("Gold")})
+	checkEvent(t, event, err, handler.Failed, cloudformation.HandlerErrorCodeAlreadyExists)
+}
+
+func TestCreateRetry(t *testing.T) {
+	store := useMemoryStore(t)
+	event, err := Create(newRequest(), &Model{}, newModel("Sparkles", "Pink"))
+	checkEvent(t, event, err, handler.Success, "")
+	uid := aws.StringValue(event.ResourceModel.(*Model).UID)
+
+	// CloudFormation retries with the same stack, logical ID and properties.
+	event, err = Create(newRequest(), &Model{}, newModel("Sparkles", "Pink"))
+	checkEvent(t, event, err, handler.Success, "")
+	if retried := aws.StringValue(event.ResourceModel.(*Model).UID); retried != uid {
+		t.Errorf("retried Create UID = %s, want %s", retried, uid)
+	}
+	if unicorns, _, _ := store.List("", 10); len(unicorns) != 1 {
+		t.Errorf("stored %d unicorns, want 1", len(unicorns))
+	}
+}
+
+func TestCreateRetryScansOnce(t *testing.T) {
+	isolate(t)
+	captureMetrics(t)
+
+	// More unicorns than fit on a page of scanPageSize.
+	docs := make([]fakecrud.Document, 2*scanPageSize+1)
+	for i := range docs {
+		docs[i] = fakecrud.Document{"_id": fmt.Sprintf("%024x", i+1), "name": fmt.Sprintf("Unicorn %d", i), "color": "Pink"}
+	}
+	b, err := json.Marshal(map[string][]fakecrud.Document{"key/unicorns": docs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "fakecrud.json")
+	if err := ioutil.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := fakecrud.NewFileServer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scans, posts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/key/unicorns" {
+			switch r.Method {
+			case http.MethodGet:
+				atomic.AddInt32(&scans, 1)
+			case http.MethodPost:
+				atomic.AddInt32(&posts, 1)
+			}
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	Store = NewCrudCrudStore(srv.URL + "/key/unicorns")
+	t.Cleanup(func() { Store = nil })
+
+	var uid string
+	for attempt := 1; attempt <= 2; attempt++ {
+		event, err := Create(newRequest(), &Model{}, newModel("Sparkles", "Pink"))
+		checkEvent(t, event, err, handler.Success, "")
+		got := aws.StringValue(event.ResourceModel.(*Model).UID)
+		if attempt == 1 {
+			uid = got
+		} else if got != uid {
+			t.Errorf("retried Create UID = %s, want %s", got, uid)
+		}
+		if n := atomic.LoadInt32(&scans); n != int32(attempt) {
+			t.Errorf("attempt %d: fetched the collection %d times, want once per attempt", attempt, n)
+		}
+	}
+	if n := atomic.LoadInt32(&posts); n != 1 {
+		t.Errorf("POSTed %d unicorns, want 1", n)
+	}
+}
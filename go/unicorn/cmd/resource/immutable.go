@@ -0,0 +1,69 @@
+package resource
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// createOnlyProperties returns the createOnlyProperties of the resource
+// schema as paths of Model fields, e.g. "/properties/Stable/Name" becomes
+// "Stable.Name", so the two cannot drift apart.
+func createOnlyProperties() []string {
+	var paths []string
+	for _, p := range resourceSchema.CreateOnlyProperties {
+		p = strings.TrimPrefix(p, "/properties/")
+		paths = append(paths, strings.Replace(p, "/", ".", -1))
+	}
+	return paths
+}
+
+// changedCreateOnly returns the create-only properties whose
+// value differs between prevModel and currentModel.
+func changedCreateOnly(prevModel *Model, currentModel *Model) []string {
+	if prevModel == nil {
+		return nil
+	}
+	var changed []string
+	for _, path := range createOnlyProperties() {
+		if !reflect.DeepEqual(field(prevModel, path), field(currentModel, path)) {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// field returns the value of the Model field at path, such as "Stable.Name",
+// or nil when the field, or a struct on the way to it, is not set.
+func field(model *Model, path string) interface{} {
+	v := reflect.ValueOf(model)
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return nil
+		}
+		if v = v.FieldByName(name); !v.IsValid() {
+			return nil
+		}
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// notUpdatable returns the failed event for an update that changes create-only properties.
+func notUpdatable(properties []string) handler.ProgressEvent {
+	return handler.ProgressEvent{
+		OperationStatus:  handler.Failed,
+		HandlerErrorCode: cloudformation.HandlerErrorCodeNotUpdatable,
+		Message:          "Create-only properties cannot be updated: " + strings.Join(properties, ", "),
+	}
+}
@@ -0,0 +1,77 @@
+package resource
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestCreateOnlyPropertiesFromSchema(t *testing.T) {
+	if got, want := createOnlyProperties(), []string{"Species"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("createOnlyProperties() = %v, want %v", got, want)
+	}
+}
+
+func TestChangedCreateOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		prev    *Model
+		current *Model
+		want    []string
+	}{
+		{
+			name:    "unchanged",
+			prev:    &Model{Species: aws.String("Pegasus"), Color: aws.String("Pink")},
+			current: &Model{Species: aws.String("Pegasus"), Color: aws.String("Gold")},
+		},
+		{
+			name:    "changed",
+			prev:    &Model{Species: aws.String("Pegasus")},
+			current: &Model{Species: aws.String("Alicorn")},
+			want:    []string{"Species"},
+		},
+		{
+			name:    "set",
+			prev:    &Model{},
+			current: &Model{Species: aws.String("Pegasus")},
+			want:    []string{"Species"},
+		},
+		{
+			name:    "no previous model",
+			current: &Model{Species: aws.String("Pegasus")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changedCreateOnly(tt.prev, tt.current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changedCreateOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestField(t *testing.T) {
+	model := &Model{
+		Species: aws.String("Pegasus"),
+		Stable:  &Stable{Name: aws.String("Cloud Nine")},
+	}
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{path: "Species", want: aws.String("Pegasus")},
+		{path: "Stable.Name", want: aws.String("Cloud Nine")},
+		{path: "Stable.Region", want: nil},
+		{path: "Name", want: nil},
+		{path: "Unknown", want: nil},
+	}
+	for _, tt := range tests {
+		if got := field(model, tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("field(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if got := field(&Model{}, "Stable.Name"); got != nil {
+		t.Errorf("field(Stable.Name) of a model without Stable = %v, want nil", got)
+	}
+}
@@ -0,0 +1,190 @@
+package resource
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+)
+
+// redacted replaces secrets in log entries.
+const redacted = "[REDACTED]"
+
+// maxSecrets is how many secrets are redacted. A warm Lambda serves every
+// account and region the type is registered in, each with its own key, and
+// every rotation adds one, so the keys that were not used for the longest
+// are forgotten.
+const maxSecrets = 16
+
+// secrets are the values, such as the API key, that are
+// replaced in every log entry, the most recently added last.
+// They are kept across invocations like the API key cache.
+var secrets struct {
+	sync.Mutex
+	values []string
+}
+
+// addSecret makes every later log entry replace s with redacted.
+func addSecret(s string) {
+	if s == "" {
+		return
+	}
+	secrets.Lock()
+	defer secrets.Unlock()
+	values := secrets.values[:0]
+	for _, v := range secrets.values {
+		if v != s {
+			values = append(values, v)
+		}
+	}
+	values = append(values, s)
+	if len(values) > maxSecrets {
+		values = values[len(values)-maxSecrets:]
+	}
+	secrets.values = values
+}
+
+// redact returns s with every known secret replaced.
+func redact(s string) string {
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, v := range secrets.values {
+		s = strings.Replace(s, v, redacted, -1)
+	}
+	return s
+}
+
+// logMu serializes writes so concurrent entries do not interleave.
+var logMu sync.Mutex
+
+// A Logger writes structured log entries, one JSON object per line, to the
+// output of the standard logger. The cfn plugin points that output at the
+// provider log group and sends every line as soon as it is written.
+// A nil Logger discards everything.
+type Logger struct {
+	fields map[string]interface{}
+}
+
+// newLogger returns a Logger whose entries identify the handler invocation:
+// the action, the logical resource, the stack and, when known, the UID of
+// the unicorn and the callback attempt.
+func newLogger(req handler.Request, action string, model *Model) *Logger {
+	l := &Logger{fields: map[string]interface{}{
+		"action":            action,
+		"logicalResourceId": req.LogicalResourceID,
+		"stackId":           req.RequestContext.StackID,
+	}}
+	if model != nil && model.UID != nil {
+		l.fields["uid"] = *model.UID
+	}
+	if s, ok := stabilizationFrom(req.CallbackContext); ok {
+		l.fields["attempt"] = s.Attempt
+		if s.UID != "" {
+			l.fields["uid"] = s.UID
+		}
+	}
+	return l
+}
+
+// With returns a copy of l that adds key and value to every entry.
+func (l *Logger) With(key string, value interface{}) *Logger {
+	if l == nil {
+		return nil
+	}
+	c := &Logger{fields: make(map[string]interface{}, len(l.fields)+1)}
+	for k, v := range l.fields {
+		c.fields[k] = v
+	}
+	c.fields[key] = value
+	return c
+}
+
+// action returns the handler action l logs for, or "" if it has none.
+func (l *Logger) action() string {
+	if l == nil {
+		return ""
+	}
+	a, _ := l.fields["action"].(string)
+	return a
+}
+
+// Info logs msg with the fields of l and the alternating keys and values in kv.
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.write("info", msg, kv)
+}
+
+// Error logs msg like Info, at error level.
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.write("error", msg, kv)
+}
+
+// done logs the outcome of the handler invocation that started at started.
+func (l *Logger) done(event *handler.ProgressEvent, err error, started time.Time) {
+	kv := []interface{}{
+		"status", event.OperationStatus,
+		"durationMs", milliseconds(time.Since(started)),
+	}
+	if m, ok := event.ResourceModel.(*Model); ok && m != nil && m.UID != nil {
+		kv = append(kv, "uid", *m.UID)
+	}
+	if event.HandlerErrorCode != "" {
+		kv = append(kv, "errorCode", event.HandlerErrorCode)
+	}
+	if event.Message != "" {
+		kv = append(kv, "message", event.Message)
+	}
+	if err != nil {
+		kv = append(kv, "error", err)
+	}
+	if event.OperationStatus == handler.Failed || err != nil {
+		l.Error("Handler failed", kv...)
+		return
+	}
+	l.Info("Handler finished", kv...)
+}
+
+func (l *Logger) write(level, msg string, kv []interface{}) {
+	if l == nil {
+		return
+	}
+	entry := make(map[string]interface{}, len(l.fields)+len(kv)/2+3)
+	for k, v := range l.fields {
+		entry[k] = loggable(v)
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if k, ok := kv[i].(string); ok {
+			entry[k] = loggable(kv[i+1])
+		}
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level
+	entry["msg"] = redact(msg)
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		b, _ = json.Marshal(map[string]string{"level": "error", "msg": "Unable to encode log entry: " + err.Error()})
+	}
+	logMu.Lock()
+	defer logMu.Unlock()
+	// One Write per entry, so each line becomes one log event.
+	log.Writer().Write(append(b, '\n'))
+}
+
+// loggable converts errors to their message and redacts secrets from strings.
+func loggable(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return redact(v.Error())
+	case string:
+		return redact(v)
+	}
+	return v
+}
+
+// milliseconds returns d in milliseconds, with microsecond precision.
+func milliseconds(d time.Duration) float64 {
+	return float64(d/time.Microsecond) / 1000
+}
@@ -0,0 +1,134 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/brianterry/unicorn-maker/go/fakecrud"
+)
+
+// captureLogs collects the handler logs written during the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	out := log.Writer()
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(out) })
+	return buf
+}
+
+// logEntries decodes the log entries in buf, failing the test
+// unless every line is exactly one JSON object.
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		var entry map[string]interface{}
+		dec := json.NewDecoder(strings.NewReader(line))
+		if err := dec.Decode(&entry); err != nil || dec.More() {
+			t.Fatalf("log line %q is not one JSON object: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLogCreate(t *testing.T) {
+	const apiKey = "0123456789abcdef0123456789abcdef"
+	isolate(t)
+	captureMetrics(t)
+	srv := httptest.NewServer(fakecrud.NewServer())
+	defer srv.Close()
+	t.Setenv(EnvEndpoint, srv.URL)
+	t.Setenv(EnvAPIKey, apiKey)
+	t.Setenv(EnvMode, ModeCallback)
+
+	// The first invocation creates the unicorn, the second one,
+	// reinvoked with the callback context, confirms it.
+	logs := captureLogs(t)
+	event, err := Create(newRequest(), &Model{}, newModel("Sparkles", "Pink"))
+	checkEvent(t, event, err, handler.InProgress, "")
+	created := logEntries(t, logs)
+
+	logs.Reset()
+	req := reinvoke(t, event)
+	event, err = Create(req, &Model{}, newModel("Sparkles", "Pink"))
+	checkEvent(t, event, err, handler.Success, "")
+	uid := *event.ResourceModel.(*Model).UID
+	stabilized := logEntries(t, logs)
+
+	check := func(entries []map[string]interface{}, want map[string]interface{}) {
+		t.Helper()
+		requests := 0
+		for _, entry := range entries {
+			for k, v := range want {
+				if entry[k] != v {
+					t.Errorf("%s = %v in %v, want %v", k, entry[k], entry, v)
+				}
+			}
+			if entry["msg"] == "Backend request" {
+				requests++
+				if _, ok := entry["latencyMs"].(float64); !ok {
+					t.Errorf("latencyMs = %v in %v, want a number", entry["latencyMs"], entry)
+				}
+			}
+		}
+		if requests == 0 {
+			t.Errorf("no backend request logged in %v", entries)
+		}
+		if last := entries[len(entries)-1]; last["msg"] != "Handler finished" {
+			t.Errorf("last entry = %v, want Handler finished", last)
+		}
+	}
+	invocation := map[string]interface{}{
+		"action":            "Create",
+		"logicalResourceId": testLogicalID,
+		"stackId":           testStackID,
+	}
+	check(created, invocation)
+	invocation["uid"] = uid
+	invocation["attempt"] = 0.0
+	check(stabilized, invocation)
+
+	// The API key is redacted wherever it appears.
+	if strings.Contains(fmt.Sprint(created, stabilized), apiKey) {
+		t.Error("logs contain the API key")
+	}
+	logs.Reset()
+	newLogger(req, "Create", nil).Error("Request to "+srv.URL+"/"+apiKey+" failed", "error", errors.New("bad key "+apiKey))
+	entry := logEntries(t, logs)[0]
+	if want := "Request to " + srv.URL + "/" + redacted + " failed"; entry["msg"] != want {
+		t.Errorf("msg = %v, want %s", entry["msg"], want)
+	}
+	if want := "bad key " + redacted; entry["error"] != want {
+		t.Errorf("error = %v, want %s", entry["error"], want)
+	}
+}
+
+func TestAddSecretKeepsRecentSecrets(t *testing.T) {
+	values := secrets.values
+	secrets.values = nil
+	t.Cleanup(func() { secrets.values = values })
+
+	for i := 0; i < maxSecrets+5; i++ {
+		addSecret(fmt.Sprintf("key-%d", i))
+		// The first key stays in use, so it is never forgotten.
+		addSecret("key-0")
+	}
+	if len(secrets.values) != maxSecrets {
+		t.Fatalf("%d secrets kept, want %d", len(secrets.values), maxSecrets)
+	}
+	if got := redact("key-0 key-5"); got != redacted+" key-5" {
+		t.Errorf("redact() = %q, want the oldest unused key forgotten", got)
+	}
+	if got := redact(fmt.Sprintf("key-%d", maxSecrets+4)); got != redacted {
+		t.Errorf("redact() = %q, want the newest key redacted", got)
+	}
+}
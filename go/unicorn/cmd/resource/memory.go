@@ -0,0 +1,137 @@
+package resource
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// MemoryStore is an in-memory UnicornStore.
+// It is safe for concurrent use.
+type MemoryStore struct {
+	mu       sync.Mutex
+	nextID   int
+	unicorns map[string]Unicorn
+	order    []string
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		unicorns: map[string]Unicorn{},
+	}
+}
+
+// Create stores a copy of u under a newly generated ID.
+func (s *MemoryStore) Create(u *Unicorn) (*Unicorn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	created := clone(u)
+	created.ID = fmt.Sprintf("%024x", s.nextID)
+	s.unicorns[created.ID] = created
+	s.order = append(s.order, created.ID)
+	return &created, nil
+}
+
+// Get returns a copy of the unicorn with the given ID.
+func (s *MemoryStore) Get(id string) (*Unicorn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.unicorns[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	u = clone(&u)
+	return &u, nil
+}
+
+// Put replaces the unicorn with the given ID.
+func (s *MemoryStore) Put(id string, u *Unicorn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.unicorns[id]; !ok {
+		return ErrNotFound
+	}
+	updated := clone(u)
+	updated.ID = id
+	s.unicorns[id] = updated
+	return nil
+}
+
+// Delete removes the unicorn with the given ID.
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.unicorns[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.unicorns, id)
+	for i, v := range s.order {
+		if v == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// List returns a page of the stored unicorns in creation order.
+func (s *MemoryStore) List(token string, limit int) ([]Unicorn, string, error) {
+	unicorns, _ := s.scan()
+	return page(unicorns, token, limit)
+}
+
+// scan returns a copy of every stored unicorn in creation order.
+func (s *MemoryStore) scan() ([]Unicorn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unicorns := make([]Unicorn, 0, len(s.order))
+	for _, id := range s.order {
+		u := s.unicorns[id]
+		unicorns = append(unicorns, clone(&u))
+	}
+	return unicorns, nil
+}
+
+// clone returns a deep copy of u, so callers cannot change stored unicorns
+// through its pointers, slices and maps.
+func clone(u *Unicorn) Unicorn {
+	c := *u
+	if u.Age != nil {
+		c.Age = aws.Int(*u.Age)
+	}
+	if u.HornLength != nil {
+		c.HornLength = aws.Float64(*u.HornLength)
+	}
+	if u.HasWings != nil {
+		c.HasWings = aws.Bool(*u.HasWings)
+	}
+	if u.Abilities != nil {
+		c.Abilities = append([]string(nil), u.Abilities...)
+	}
+	if u.Stable != nil {
+		stable := *u.Stable
+		c.Stable = &stable
+	}
+	c.Tags = copyTags(u.Tags)
+	c.StackTags = copyTags(u.StackTags)
+	return c
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
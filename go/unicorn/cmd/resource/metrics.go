@@ -0,0 +1,108 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// MetricsNamespace is the CloudWatch namespace of the backend request metrics.
+const MetricsNamespace = "Brianterry/UnicornMaker"
+
+// metricsOutput receives the metrics of the stores the handlers use.
+// Lambda sends stdout to the log group of the function, where CloudWatch
+// extracts metrics written in embedded metric format, so publishing them
+// needs no API permissions.
+var metricsOutput io.Writer = os.Stdout
+
+// metricsMu serializes writes so concurrent documents do not interleave.
+var metricsMu sync.Mutex
+
+// emfMetric names a metric of an embedded metric format document.
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+// emfDirective tells CloudWatch which members of the document are
+// metrics and which are dimensions.
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// requestMetrics is an embedded metric format document for one backend
+// request, including its retries. Requests, Latency, Retries and Errors
+// are published per Action and per Action and StatusClass; Method is
+// kept as a searchable property.
+type requestMetrics struct {
+	AWS         emfMetadata `json:"_aws"`
+	Action      string      `json:"Action"`
+	StatusClass string      `json:"StatusClass"`
+	Method      string      `json:"Method"`
+	Requests    int         `json:"Requests"`
+	Latency     float64     `json:"Latency"`
+	Retries     int         `json:"Retries"`
+	Errors      int         `json:"Errors"`
+}
+
+// statusClass returns the class of the response status, such as 2xx,
+// or NetworkError when there was no response.
+func statusClass(resp *response) string {
+	if resp == nil {
+		return "NetworkError"
+	}
+	return fmt.Sprintf("%dxx", resp.StatusCode/100)
+}
+
+// recordRequest writes the metrics of a backend request that made
+// retries+1 attempts in latency and ended with resp or err to s.Metrics.
+func (s *CrudCrudStore) recordRequest(input *RequestInput, resp *response, retries int, latency time.Duration, err error) {
+	if s.Metrics == nil {
+		return
+	}
+	action := s.Action
+	if action == "" {
+		action = "None"
+	}
+	m := requestMetrics{
+		AWS: emfMetadata{
+			Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+			CloudWatchMetrics: []emfDirective{{
+				Namespace:  MetricsNamespace,
+				Dimensions: [][]string{{"Action"}, {"Action", "StatusClass"}},
+				Metrics: []emfMetric{
+					{Name: "Requests", Unit: "Count"},
+					{Name: "Latency", Unit: "Milliseconds"},
+					{Name: "Retries", Unit: "Count"},
+					{Name: "Errors", Unit: "Count"},
+				},
+			}},
+		},
+		Action:      action,
+		StatusClass: statusClass(resp),
+		Method:      input.Method,
+		Requests:    1,
+		Latency:     milliseconds(latency),
+		Retries:     retries,
+	}
+	if err != nil {
+		m.Errors = 1
+	}
+	b, jerr := json.Marshal(&m)
+	if jerr != nil {
+		return
+	}
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	s.Metrics.Write(append(b, '\n'))
+}
@@ -0,0 +1,127 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// captureMetrics collects the metrics written during the test.
+func captureMetrics(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	out := metricsOutput
+	metricsOutput = buf
+	t.Cleanup(func() { metricsOutput = out })
+	return buf
+}
+
+// testRetryPolicy retries without waiting.
+var testRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	BaseDelay:      time.Millisecond,
+	MaxDelay:       time.Millisecond,
+	RequestTimeout: time.Second,
+}
+
+func TestRecordRequestEMF(t *testing.T) {
+	tests := []struct {
+		name        string
+		statuses    []int
+		wantErr     bool
+		statusClass string
+		retries     int
+		errors      int
+	}{
+		{name: "ok", statuses: []int{200}, statusClass: "2xx"},
+		{name: "retried 5xx", statuses: []int{503, 500, 200}, statusClass: "2xx", retries: 2},
+		{name: "exhausted 5xx", statuses: []int{503, 503, 503, 503}, wantErr: true, statusClass: "5xx", retries: 3, errors: 1},
+		{name: "4xx", statuses: []int{404}, wantErr: true, statusClass: "4xx", errors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			attempt := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[attempt]
+				attempt++
+				w.WriteHeader(status)
+				if status == http.StatusOK {
+					w.Write([]byte(`{"_id":"1","name":"Sparkles"}`))
+				}
+			}))
+			defer srv.Close()
+			store := NewCrudCrudStore(srv.URL + "/unicorns")
+			store.Retry = testRetryPolicy
+			store.Action = "Read"
+			store.Metrics = buf
+
+			_, err := store.Get("1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get() error = %v, want error %v", err, tt.wantErr)
+			}
+			if attempt != len(tt.statuses) {
+				t.Fatalf("made %d attempts, want %d", attempt, len(tt.statuses))
+			}
+
+			// One document per request, whatever the number of attempts.
+			var doc map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatalf("metrics %q: %v", buf.String(), err)
+			}
+			var meta struct {
+				Timestamp         int64
+				CloudWatchMetrics []struct {
+					Namespace  string
+					Dimensions [][]string
+					Metrics    []struct{ Name, Unit string }
+				}
+			}
+			b, _ := json.Marshal(doc["_aws"])
+			if err := json.Unmarshal(b, &meta); err != nil || len(meta.CloudWatchMetrics) != 1 {
+				t.Fatalf("_aws = %s, %v", b, err)
+			}
+			if meta.Timestamp == 0 {
+				t.Error("_aws.Timestamp is not set")
+			}
+			directive := meta.CloudWatchMetrics[0]
+			if directive.Namespace != MetricsNamespace {
+				t.Errorf("Namespace = %s, want %s", directive.Namespace, MetricsNamespace)
+			}
+			if want := [][]string{{"Action"}, {"Action", "StatusClass"}}; !reflect.DeepEqual(directive.Dimensions, want) {
+				t.Errorf("Dimensions = %v, want %v", directive.Dimensions, want)
+			}
+			// Every dimension and metric must be a member of the document.
+			for _, m := range directive.Metrics {
+				if _, ok := doc[m.Name].(float64); !ok {
+					t.Errorf("metric %s = %v, want a number", m.Name, doc[m.Name])
+				}
+			}
+			for _, dims := range directive.Dimensions {
+				for _, d := range dims {
+					if _, ok := doc[d].(string); !ok {
+						t.Errorf("dimension %s = %v, want a string", d, doc[d])
+					}
+				}
+			}
+
+			want := map[string]interface{}{
+				"Action":      "Read",
+				"StatusClass": tt.statusClass,
+				"Method":      "GET",
+				"Requests":    1.0,
+				"Retries":     float64(tt.retries),
+				"Errors":      float64(tt.errors),
+			}
+			for k, v := range want {
+				if doc[k] != v {
+					t.Errorf("%s = %v, want %v", k, doc[k], v)
+				}
+			}
+		})
+	}
+}
@@ -4,7 +4,30 @@ package resource
 
 // Model is autogenerated from the json schema
 type Model struct {
-	UID   *string `json:",omitempty"`
-	Name  *string `json:",omitempty"`
-	Color *string `json:",omitempty"`
+	UID        *string  `json:",omitempty"`
+	Name       *string  `json:",omitempty"`
+	Color      *string  `json:",omitempty"`
+	Species    *string  `json:",omitempty"`
+	Tags       []Tag    `json:",omitempty"`
+	Age        *int     `json:",omitempty"`
+	HornLength *float64 `json:",omitempty"`
+	HasWings   *bool    `json:",omitempty"`
+	Abilities  []string `json:",omitempty"`
+	Stable     *Stable  `json:",omitempty"`
+	CreatedAt  *string  `json:",omitempty"`
+	UpdatedAt  *string  `json:",omitempty"`
+	Arn        *string  `json:",omitempty"`
+	Version    *int     `json:",omitempty"`
+}
+
+// Tag is autogenerated from the json schema
+type Tag struct {
+	Key   *string `json:",omitempty"`
+	Value *string `json:",omitempty"`
+}
+
+// Stable is autogenerated from the json schema
+type Stable struct {
+	Name   *string `json:",omitempty"`
+	Region *string `json:",omitempty"`
 }
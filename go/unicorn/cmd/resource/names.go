@@ -0,0 +1,59 @@
+package resource
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// findByName returns the unicorn with the given name. Name is an
+// additional identifier, so it fails with NotFound when no unicorn has
+// the name and with InvalidRequest when more than one does.
+func findByName(store UnicornStore, name string) (*Unicorn, error) {
+	var found *Unicorn
+	dup, err := findUnicorn(store, func(u *Unicorn) bool {
+		if u.Name != name {
+			return false
+		}
+		if found == nil {
+			c := *u
+			found = &c
+			return false
+		}
+		// A second unicorn with the name stops the scan.
+		return true
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case dup != nil:
+		return nil, &StoreError{
+			Code:    cloudformation.HandlerErrorCodeInvalidRequest,
+			Message: fmt.Sprintf("More than one unicorn is named %q, use its UID instead", name),
+		}
+	case found == nil:
+		return nil, ErrNotFound
+	}
+	return found, nil
+}
+
+// nameAvailable fails with AlreadyExists when a unicorn has the given name.
+func nameAvailable(store UnicornStore, name string) error {
+	u, err := findUnicorn(store, func(u *Unicorn) bool {
+		return u.Name == name
+	})
+	if err != nil {
+		return err
+	}
+	if u != nil {
+		return nameTaken(name)
+	}
+	return nil
+}
+
+func nameTaken(name string) *StoreError {
+	return &StoreError{
+		Code:    cloudformation.HandlerErrorCodeAlreadyExists,
+		Message: fmt.Sprintf("A unicorn named %q already exists", name),
+	}
+}
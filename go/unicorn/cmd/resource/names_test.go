@@ -0,0 +1,96 @@
+package resource
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// readEvent returns the request and model the plugin builds from a handler event in testdata.
+func readEvent(t *testing.T, name string) (handler.Request, *Model) {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event struct {
+		AccountID   string `json:"awsAccountId"`
+		Region      string `json:"region"`
+		StackID     string `json:"stackId"`
+		RequestData struct {
+			LogicalResourceID  string          `json:"logicalResourceId"`
+			ResourceProperties json.RawMessage `json:"resourceProperties"`
+		} `json:"requestData"`
+	}
+	if err := json.Unmarshal(b, &event); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	req := handler.NewRequest(event.RequestData.LogicalResourceID, nil, handler.RequestContext{
+		StackID:   event.StackID,
+		Region:    event.Region,
+		AccountID: event.AccountID,
+	}, nil, nil, event.RequestData.ResourceProperties, nil)
+	model := &Model{}
+	if err := req.Unmarshal(model); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return req, model
+}
+
+func TestReadImportEvent(t *testing.T) {
+	tests := []struct {
+		name   string
+		seed   []string
+		status handler.Status
+		code   string
+	}{
+		{
+			name:   "named unicorn",
+			seed:   []string{"Sparkles", "Glitter"},
+			status: handler.Success,
+		},
+		{
+			name:   "no unicorn with the name",
+			seed:   []string{"Glitter"},
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeNotFound,
+		},
+		{
+			name:   "several unicorns with the name",
+			seed:   []string{"Sparkles", "Sparkles"},
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeInvalidRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useMemoryStore(t)
+			// Unicorns created outside of CloudFormation, which does not check names.
+			var want *Unicorn
+			for _, name := range tt.seed {
+				u, err := store.Create(&Unicorn{Name: name, Color: "Pink"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if name == "Sparkles" {
+					want = u
+				}
+			}
+
+			req, model := readEvent(t, "import.json")
+			event, err := Read(req, &Model{}, model)
+			checkEvent(t, event, err, tt.status, tt.code)
+			if tt.status != handler.Success {
+				return
+			}
+			if got := event.ResourceModel.(*Model); aws.StringValue(got.UID) != want.ID {
+				t.Errorf("Read() UID = %s, want %s", aws.StringValue(got.UID), want.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package resource
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// DefaultPageSize is the number of unicorns List returns per page when none is configured.
+const DefaultPageSize = 100
+
+// ErrInvalidToken is returned when a NextToken cannot be decoded.
+var ErrInvalidToken = &StoreError{
+	Code:    cloudformation.HandlerErrorCodeInvalidRequest,
+	Message: "Invalid NextToken",
+}
+
+// pageToken is the position a NextToken resumes from. It is encoded as
+// base64 JSON so callers treat it as opaque.
+type pageToken struct {
+	// After is the ID of the last unicorn on the previous page.
+	After string `json:"after"`
+	// Offset is the index the next page started at when the token was made.
+	// It is used if the unicorn After has been deleted since.
+	Offset int `json:"offset"`
+}
+
+func (t *pageToken) String() string {
+	b, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parsePageToken(s string) (*pageToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	t := &pageToken{}
+	if err := json.Unmarshal(b, t); err != nil || t.Offset < 0 {
+		return nil, ErrInvalidToken
+	}
+	return t, nil
+}
+
+// page returns up to limit unicorns starting at the position token
+// points to, and the token for the following page, which is empty
+// on the last page. Backends without server side paging use it to
+// page through the whole collection.
+func page(unicorns []Unicorn, token string, limit int) ([]Unicorn, string, error) {
+	start := 0
+	if token != "" {
+		t, err := parsePageToken(token)
+		if err != nil {
+			return nil, "", err
+		}
+		// If After was deleted, everything behind it moved up by one.
+		// Resuming one place early may repeat a unicorn, but never skips one.
+		start = t.Offset - 1
+		if start < 0 {
+			start = 0
+		}
+		for i := range unicorns {
+			if unicorns[i].ID == t.After {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if start > len(unicorns) {
+		start = len(unicorns)
+	}
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	end := start + limit
+	if end >= len(unicorns) {
+		return unicorns[start:], "", nil
+	}
+	next := &pageToken{After: unicorns[end-1].ID, Offset: end}
+	return unicorns[start:end], next.String(), nil
+}
+
+// pageSize returns the configured List page size.
+func pageSize() int {
+	if cfg, err := readConfig(); err == nil {
+		return cfg.PageSize
+	}
+	return DefaultPageSize
+}
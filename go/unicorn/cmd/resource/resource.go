@@ -1,21 +1,16 @@
 package resource
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
-	"io"
-	"net/http"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudformation"
 )
 
-// APIEndpoint is the crudcrud endpoint.
-// You can  obtain an endpoint by going to https://crudcrud.com.
-const APIEndpoint = "https://crudcrud.com/api/<Your API ID>/unicorns"
-
 // A Unicorn represents a unicorn.
 type Unicorn struct {
 	// ID is the ID of the unicorn.
@@ -24,237 +19,430 @@ type Unicorn struct {
 	Name string `json:"name,omitempty"`
 	// Color is the color of the unicorn.
 	Color string `json:"color,omitempty"`
+	// Species is the species of the unicorn. It is set on create only.
+	Species string `json:"species,omitempty"`
+	// Age is the age of the unicorn in years.
+	// Numbers and booleans are pointers so zero values are kept.
+	Age *int `json:"age,omitempty"`
+	// HornLength is the length of the horn in centimeters.
+	HornLength *float64 `json:"hornLength,omitempty"`
+	// HasWings reports whether the unicorn has wings.
+	HasWings *bool `json:"hasWings,omitempty"`
+	// Abilities are the magical abilities of the unicorn, in order.
+	Abilities []string `json:"abilities,omitempty"`
+	// Stable is the stable the unicorn lives in.
+	Stable *UnicornStable `json:"stable,omitempty"`
+	// Tags are the tags set on the unicorn resource.
+	Tags map[string]string `json:"tags,omitempty"`
+	// StackTags are the tags of the stack the unicorn belongs to.
+	StackTags map[string]string `json:"stackTags,omitempty"`
+	// CreatedAt is when the unicorn was created, in RFC 3339 format.
+	CreatedAt string `json:"createdAt,omitempty"`
+	// UpdatedAt is when the unicorn was last changed, in RFC 3339 format.
+	UpdatedAt string `json:"updatedAt,omitempty"`
+	// Version counts the changes made to the unicorn, starting at 1.
+	Version int `json:"version,omitempty"`
+	// Region and AccountID are where the unicorn was created. They make up its ARN.
+	Region    string `json:"region,omitempty"`
+	AccountID string `json:"accountId,omitempty"`
+	// StackID and LogicalResourceID identify the resource that created the unicorn.
+	StackID           string `json:"stackId,omitempty"`
+	LogicalResourceID string `json:"logicalResourceId,omitempty"`
+	// IdempotencyKey identifies the Create request that made the unicorn.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
 }
 
-//RequestInput represents the input when making the HTTP request.
-type RequestInput struct {
-	// Method is the the HTTP request method.
-	Method string
-	// URL is the request URL
-	URL string
-	// Body is the body of the request.
-	Body io.Reader
-	// Action is the Cloudformation resource action
-	// Create, Read, etc...
-	Action string
-	// Model is the resource model.
-	Model *Model
+// A UnicornStable is the stable a unicorn lives in.
+type UnicornStable struct {
+	// Name is the name of the stable.
+	Name string `json:"name,omitempty"`
+	// Region is the region the stable is in.
+	Region string `json:"region,omitempty"`
 }
 
 // Create handles the Create event from the Cloudformation service.
-func Create(req handler.Request, prevModel *Model, currentModel *Model) (handler.ProgressEvent, error) {
-	if err := validateInput(req, currentModel); err != nil {
+func Create(req handler.Request, prevModel *Model, currentModel *Model) (event handler.ProgressEvent, err error) {
+	l, span := newLogger(req, "Create", currentModel), startTrace(req, "Create", currentModel)
+	l.Info("Handler invoked")
+	defer finish(l, span, &event, &err, time.Now())
+	// In callback mode the handler is reinvoked until the change is visible.
+	if s, ok := stabilizationFrom(req.CallbackContext); ok {
+		return stabilize(req, l, span, s, currentModel), nil
+	}
+	normalize(currentModel)
+	if invalid := validateModel(req, currentModel); invalid != nil {
+		return *invalid, nil
+	}
+	store, err := storeFor(req, l, span)
+	if err != nil {
+		return failed(err), nil
+	}
+	if err := validateInput(store, currentModel); err != nil {
 		return handler.ProgressEvent{
 			OperationStatus:  handler.Failed,
 			Message:          err.Error(),
 			HandlerErrorCode: cloudformation.HandlerErrorCodeInvalidRequest,
 		}, nil
 	}
-	reqBody, err := marshal(currentModel)
+	// A retried Create returns the unicorn the earlier attempt stored
+	// instead of creating a duplicate.
+	key := idempotencyKey(req, currentModel)
+	u, err := findCreated(store, req, key, aws.StringValue(currentModel.Name))
 	if err != nil {
-		return handler.ProgressEvent{}, err
-	}
-	response := makeRequest(&RequestInput{
-		Method: "POST",
-		URL:    APIEndpoint,
-		Body:   bytes.NewBuffer(reqBody),
-		Action: "Create",
-	})
-	return response, nil
+		return failed(err), nil
+	}
+	if u == nil {
+		create := marshal(currentModel)
+		create.IdempotencyKey = key
+		create.StackTags = stackTags(req)
+		stampCreate(create, req, time.Now())
+		if u, err = store.Create(create); err != nil {
+			return failed(err), nil
+		}
+	}
+	if inCallbackMode() {
+		s := &stabilization{Action: "Create", UID: u.ID, Started: time.Now()}
+		return s.inProgress(unmarshal(u)), nil
+	}
+	return handler.ProgressEvent{
+		OperationStatus: handler.Success,
+		Message:         "Create Complete",
+		ResourceModel:   unmarshal(u),
+	}, nil
 }
 
 // Read handles the Read event from the Cloudformation service.
-func Read(req handler.Request, prevModel *Model, currentModel *Model) (handler.ProgressEvent, error) {
-	if currentModel.UID == nil {
-		return handler.ProgressEvent{
-			OperationStatus:  handler.Failed,
-			Message:          "Resource not found",
-			HandlerErrorCode: cloudformation.HandlerErrorCodeNotFound,
-		}, nil
+func Read(req handler.Request, prevModel *Model, currentModel *Model) (event handler.ProgressEvent, err error) {
+	l, span := newLogger(req, "Read", currentModel), startTrace(req, "Read", currentModel)
+	l.Info("Handler invoked")
+	defer finish(l, span, &event, &err, time.Now())
+	if currentModel.UID == nil && currentModel.Name == nil {
+		return failed(ErrNotFound), nil
+	}
+	store, err := storeFor(req, l, span)
+	if err != nil {
+		return failed(err), nil
+	}
+	var u *Unicorn
+	if currentModel.UID != nil {
+		u, err = store.Get(aws.StringValue(currentModel.UID))
+	} else {
+		// Name is an additional identifier, used to import unicorns
+		// created outside of CloudFormation.
+		u, err = findByName(store, aws.StringValue(currentModel.Name))
 	}
-	response := makeRequest(&RequestInput{
-		Method: "GET",
-		URL:    APIEndpoint + "/" + aws.StringValue(currentModel.UID),
-		Action: "Read",
-	})
-	return response, nil
+	if err != nil {
+		return failed(err), nil
+	}
+	return handler.ProgressEvent{
+		OperationStatus: handler.Success,
+		Message:         "Read Complete",
+		ResourceModel:   unmarshal(u),
+	}, nil
 }
 
 // Update handles the Update event from the Cloudformation service.
-func Update(req handler.Request, prevModel *Model, currentModel *Model) (handler.ProgressEvent, error) {
-	if !exist(req, currentModel) {
-		return handler.ProgressEvent{
-			OperationStatus:  handler.Failed,
-			Message:          "Resource not found",
-			HandlerErrorCode: cloudformation.HandlerErrorCodeNotFound,
-		}, nil
+func Update(req handler.Request, prevModel *Model, currentModel *Model) (event handler.ProgressEvent, err error) {
+	l, span := newLogger(req, "Update", currentModel), startTrace(req, "Update", currentModel)
+	l.Info("Handler invoked")
+	defer finish(l, span, &event, &err, time.Now())
+	if s, ok := stabilizationFrom(req.CallbackContext); ok {
+		return stabilize(req, l, span, s, currentModel), nil
+	}
+	if currentModel.UID == nil {
+		return failed(ErrNotFound), nil
+	}
+	normalize(prevModel)
+	normalize(currentModel)
+	if invalid := validateModel(req, currentModel); invalid != nil {
+		return *invalid, nil
+	}
+	if changed := changedCreateOnly(prevModel, currentModel); len(changed) > 0 {
+		return notUpdatable(changed), nil
 	}
-	reqBody, err := marshal(currentModel)
+	// Nothing to send if neither the properties nor the stack tags changed.
+	// Stack tags are not part of the model, so the backend is only asked
+	// for the stored ones when the request carries any.
+	noop := unchanged(prevModel, currentModel)
+	if noop && len(req.RequestContext.StackTags) == 0 {
+		return updated(prevModel, currentModel), nil
+	}
+	store, err := storeFor(req, l, span)
+	if err != nil {
+		return failed(err), nil
+	}
+	uid := aws.StringValue(currentModel.UID)
+	existing, err := store.Get(uid)
+	if err != nil {
+		return failed(err), nil
+	}
+	if noop && reflect.DeepEqual(stackTags(req), existing.StackTags) {
+		return updated(prevModel, currentModel), nil
+	}
+	// Refuse to overwrite changes made since CloudFormation last read the unicorn.
+	if expected := expectedVersion(prevModel, currentModel); expected != 0 && existing.Version != expected {
+		return conflict(uid, expected, existing.Version), nil
+	}
+	if name := aws.StringValue(currentModel.Name); name != existing.Name {
+		if err := nameAvailable(store, name); err != nil {
+			return failed(err), nil
+		}
+	}
+	put := marshal(currentModel)
+	put.IdempotencyKey = existing.IdempotencyKey
+	put.Tags = applyTags(existing.Tags, prevModel, currentModel)
+	put.StackTags = stackTags(req)
+	stampUpdate(put, existing, time.Now())
+	if err := store.Put(uid, put); err != nil {
+		return failed(err), nil
+	}
+	if inCallbackMode() {
+		s := &stabilization{Action: "Update", UID: uid, Started: time.Now()}
+		return s.inProgress(currentModel), nil
+	}
+	// Return what the backend stored, not what we asked for.
+	u, err := store.Get(uid)
 	if err != nil {
-		return handler.ProgressEvent{}, err
-	}
-	response := makeRequest(&RequestInput{
-		Method: "PUT",
-		URL:    APIEndpoint + "/" + aws.StringValue(currentModel.UID),
-		Body:   bytes.NewBuffer(reqBody),
-		Action: "Update",
-		Model:  currentModel,
-	})
-	return response, nil
+		return failed(err), nil
+	}
+	return handler.ProgressEvent{
+		OperationStatus: handler.Success,
+		Message:         "Update Complete",
+		ResourceModel:   unmarshal(u),
+	}, nil
 }
 
 // Delete handles the Delete event from the Cloudformation service.
-func Delete(req handler.Request, prevModel *Model, currentModel *Model) (handler.ProgressEvent, error) {
-	response := makeRequest(&RequestInput{
-		Method: "DELETE",
-		URL:    APIEndpoint + "/" + aws.StringValue(currentModel.UID),
-		Body:   nil,
-		Action: "Delete",
-	})
-	return response, nil
+func Delete(req handler.Request, prevModel *Model, currentModel *Model) (event handler.ProgressEvent, err error) {
+	l, span := newLogger(req, "Delete", currentModel), startTrace(req, "Delete", currentModel)
+	l.Info("Handler invoked")
+	defer finish(l, span, &event, &err, time.Now())
+	if s, ok := stabilizationFrom(req.CallbackContext); ok {
+		return stabilize(req, l, span, s, currentModel), nil
+	}
+	if currentModel.UID == nil {
+		return failed(ErrNotFound), nil
+	}
+	store, err := storeFor(req, l, span)
+	if err != nil {
+		return failed(err), nil
+	}
+	// CloudFormation expects NotFound when the unicorn is already gone.
+	uid := aws.StringValue(currentModel.UID)
+	if _, err := store.Get(uid); err != nil {
+		return failed(err), nil
+	}
+	if err := store.Delete(uid); err != nil {
+		return failed(err), nil
+	}
+	if inCallbackMode() || confirmDelete() {
+		s := &stabilization{Action: "Delete", UID: uid, Started: time.Now()}
+		return s.inProgress(nil), nil
+	}
+	return handler.ProgressEvent{
+		OperationStatus: handler.Success,
+		Message:         "Delete Complete",
+	}, nil
 }
 
 // List handles the List event from the Cloudformation service.
-func List(req handler.Request, prevModel *Model, currentModel *Model) (handler.ProgressEvent, error) {
-	response := makeRequest(&RequestInput{
-		Method: "GET",
-		URL:    APIEndpoint,
-		Action: "List",
-	})
-	return response, nil
+func List(req handler.Request, prevModel *Model, currentModel *Model) (event handler.ProgressEvent, err error) {
+	l, span := newLogger(req, "List", currentModel), startTrace(req, "List", currentModel)
+	l.Info("Handler invoked")
+	defer finish(l, span, &event, &err, time.Now())
+	store, err := storeFor(req, l, span)
+	if err != nil {
+		return failed(err), nil
+	}
+	unicorns, next, err := store.List(req.RequestContext.NextToken, pageSize())
+	if err != nil {
+		return failed(err), nil
+	}
+
+	// The cloudformation service requires that an empty array
+	// be returned if there are 0 unicorns, so models must not be nil.
+	// List only returns the primary identifier of each unicorn;
+	// CloudFormation calls Read for the rest of the properties.
+	models := make([]interface{}, 0, len(unicorns))
+	for i := range unicorns {
+		models = append(models, identifier(&unicorns[i]))
+	}
+	return handler.ProgressEvent{
+		OperationStatus: handler.Success,
+		Message:         "List Complete",
+		ResourceModels:  models,
+		NextToken:       next,
+	}, nil
 }
 
-func exist(req handler.Request, model *Model) bool {
-	event, _ := Read(req, nil, model)
-	if event.OperationStatus != handler.Failed {
-		return true
+func exist(store UnicornStore, model *Model) bool {
+	if model.UID == nil {
+		return false
 	}
-	return false
+	_, err := store.Get(aws.StringValue(model.UID))
+	return err == nil
 }
 
-func validateInput(req handler.Request, model *Model) error {
-	if exist(req, model) {
+func validateInput(store UnicornStore, model *Model) error {
+	if exist(store, model) {
 		return errors.New("Resource exist")
 	}
-	if model.Name == nil {
-		return errors.New("Name required")
-	}
-	if model.Color == nil {
-		return errors.New("Color required")
-	}
 	return nil
 }
 
-func marshal(resource *Model) ([]byte, error) {
-	u := Unicorn{}
-	if resource.Name != nil {
-		u.Name = aws.StringValue(resource.Name)
+// updated returns the event of an update that had nothing to change.
+func updated(prevModel *Model, currentModel *Model) handler.ProgressEvent {
+	copyReadOnly(prevModel, currentModel)
+	return handler.ProgressEvent{
+		OperationStatus: handler.Success,
+		Message:         "Update Complete",
+		ResourceModel:   currentModel,
 	}
-	if resource.Color != nil {
-		u.Color = aws.StringValue(resource.Color)
+}
+
+// unchanged reports whether an update from prevModel to currentModel
+// leaves every property the handlers write as it was.
+func unchanged(prevModel *Model, currentModel *Model) bool {
+	if prevModel == nil {
+		return false
 	}
-	body, err := json.Marshal(&u)
-	if err != nil {
-		return nil, err
+	if prevModel.UID != nil && aws.StringValue(prevModel.UID) != aws.StringValue(currentModel.UID) {
+		return false
 	}
-	return body, nil
+	return reflect.DeepEqual(marshal(prevModel), marshal(currentModel))
 }
 
-func makeRequest(input *RequestInput) handler.ProgressEvent {
-	// Create client
-	client := &http.Client{}
-
-	// Create request
-	re, err := http.NewRequest(input.Method, input.URL, input.Body)
-	if err != nil {
+// failed converts an error returned by the Store into a failed ProgressEvent.
+func failed(err error) handler.ProgressEvent {
+	var serr *StoreError
+	if errors.As(err, &serr) {
 		return handler.ProgressEvent{
 			OperationStatus:  handler.Failed,
-			HandlerErrorCode: cloudformation.HandlerErrorCodeInvalidRequest,
-			Message:          err.Error(),
+			HandlerErrorCode: serr.Code,
+			Message:          serr.Message,
 		}
 	}
+	return handler.NewFailedEvent(err)
+}
 
-	// If the body is not nil, we set the content header
-	if input.Body != nil {
-		re.Header.Set("Content-Type", "application/json; charset=utf-8")
+// finish ends the trace of a handler invocation that started at started
+// and logs its outcome.
+func finish(l *Logger, span *Span, event *handler.ProgressEvent, err *error, started time.Time) {
+	if xerr := span.endInvocation(event, *err); xerr != nil {
+		l.Error("Unable to export trace", "error", xerr)
 	}
+	l.done(event, *err, started)
+}
 
-	// Fetch Request
-	resp, err := client.Do(re)
-	if err != nil {
-		return handler.ProgressEvent{
-			OperationStatus:  handler.Failed,
-			HandlerErrorCode: cloudformation.HandlerErrorCodeNetworkFailure,
-			Message:          err.Error(),
-		}
+// normalize rewrites the properties of the model that have a canonical form.
+// Create and Update normalize their input, and unmarshal normalizes what
+// the backend returns, so Read reports the same values Create was given.
+func normalize(model *Model) {
+	if model == nil {
+		return
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 404 || resp.StatusCode == 400 {
-		return handler.ProgressEvent{
-			OperationStatus:  handler.Failed,
-			HandlerErrorCode: cloudformation.HandlerErrorCodeNotFound,
-		}
-
+	model.Name = trimmed(model.Name)
+	model.Species = trimmed(model.Species)
+	if model.Color != nil {
+		model.Color = aws.String(normalizeColor(aws.StringValue(model.Color)))
+	}
+	if model.Stable != nil {
+		model.Stable.Name = trimmed(model.Stable.Name)
+		model.Stable.Region = trimmed(model.Stable.Region)
 	}
-	return makeReturn(input, resp)
 }
 
-func unmarshal(unicorn *Unicorn) *Model {
-	m := Model{
-		UID:   aws.String(unicorn.ID),
-		Name:  aws.String(unicorn.Name),
-		Color: aws.String(unicorn.Color),
+// trimmed returns s without leading and trailing white space.
+func trimmed(s *string) *string {
+	if s == nil {
+		return nil
 	}
-	return &m
+	return aws.String(strings.TrimSpace(*s))
 }
 
-func makeReturn(input *RequestInput, resp *http.Response) handler.ProgressEvent {
-	result := handler.ProgressEvent{
-		OperationStatus: handler.Success,
+// optional returns nil for values the backend leaves empty, so unset
+// properties are omitted from the model rather than reported as "".
+func optional(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
 	}
-	switch input.Action {
-	case "Create":
-		u := Unicorn{}
-		if err := json.NewDecoder(resp.Body).Decode(&u); err != nil {
-			return handler.NewFailedEvent(err)
-		}
-		result.Message = "Create Complete"
-		result.ResourceModel = unmarshal(&u)
+	return aws.String(s)
+}
 
-	case "Read":
-		u := Unicorn{}
-		if err := json.NewDecoder(resp.Body).Decode(&u); err != nil {
-			return handler.NewFailedEvent(err)
+func marshal(resource *Model) *Unicorn {
+	u := Unicorn{}
+	if resource.Name != nil {
+		u.Name = strings.TrimSpace(aws.StringValue(resource.Name))
+	}
+	if resource.Color != nil {
+		u.Color = normalizeColor(aws.StringValue(resource.Color))
+	}
+	if resource.Species != nil {
+		u.Species = strings.TrimSpace(aws.StringValue(resource.Species))
+	}
+	if resource.Age != nil {
+		u.Age = aws.Int(aws.IntValue(resource.Age))
+	}
+	if resource.HornLength != nil {
+		u.HornLength = aws.Float64(aws.Float64Value(resource.HornLength))
+	}
+	if resource.HasWings != nil {
+		u.HasWings = aws.Bool(aws.BoolValue(resource.HasWings))
+	}
+	if len(resource.Abilities) > 0 {
+		u.Abilities = append([]string(nil), resource.Abilities...)
+	}
+	if resource.Stable != nil {
+		u.Stable = &UnicornStable{
+			Name:   strings.TrimSpace(aws.StringValue(resource.Stable.Name)),
+			Region: strings.TrimSpace(aws.StringValue(resource.Stable.Region)),
 		}
-		result.Message = "Read Complete"
-		result.ResourceModel = unmarshal(&u)
-
-	case "Update":
-		result.Message = "Update Complete"
-		result.ResourceModel = input.Model
-
-	case "Delete":
-		result.Message = "Delete Complete"
+	}
+	u.Tags = tagMap(resource.Tags)
+	return &u
+}
 
-	case "List":
-		var unicorns []Unicorn
+// identifier returns a model holding only the primary identifier of the unicorn.
+func identifier(unicorn *Unicorn) *Model {
+	return &Model{
+		UID: aws.String(unicorn.ID),
+	}
+}
 
-		// The cloudformation service requires that an empty array
-		// be return if there are 0 unicorns.
-		// Because the model struct has the
-		// tag: omitempty, the value is omitted
-		// in the JSON return, causing an error.
-		// So, make a slice and set it to 1 to
-		//return an empty list if there is 0 unicorn
-		models := make([]interface{}, 1)
-		if err := json.NewDecoder(resp.Body).Decode(&unicorns); err != nil {
-			return handler.NewFailedEvent(err)
+// unmarshal converts a stored unicorn into a model. Properties the
+// backend has no value for are left nil, so Read does not report drift
+// for properties the template never set.
+func unmarshal(unicorn *Unicorn) *Model {
+	m := Model{
+		UID:     aws.String(unicorn.ID),
+		Name:    optional(unicorn.Name),
+		Color:   optional(unicorn.Color),
+		Species: optional(unicorn.Species),
+	}
+	if unicorn.Age != nil {
+		m.Age = aws.Int(aws.IntValue(unicorn.Age))
+	}
+	if unicorn.HornLength != nil {
+		m.HornLength = aws.Float64(aws.Float64Value(unicorn.HornLength))
+	}
+	if unicorn.HasWings != nil {
+		m.HasWings = aws.Bool(aws.BoolValue(unicorn.HasWings))
+	}
+	if len(unicorn.Abilities) > 0 {
+		m.Abilities = append([]string(nil), unicorn.Abilities...)
+	}
+	if unicorn.Stable != nil {
+		stable := Stable{
+			Name:   optional(unicorn.Stable.Name),
+			Region: optional(unicorn.Stable.Region),
 		}
-		for _, unicorn := range unicorns {
-			models = append(models, unmarshal(&unicorn))
+		if stable != (Stable{}) {
+			m.Stable = &stable
 		}
-		result.Message = "List Complete"
-		result.ResourceModels = models
 	}
-	return result
+	m.Tags = modelTags(unicorn.Tags)
+	readOnly(&m, unicorn)
+	normalize(&m)
+	return &m
 }
@@ -0,0 +1,615 @@
+package resource
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/brianterry/unicorn-maker/go/fakecrud"
+)
+
+const (
+	testStackID   = "arn:aws:cloudformation:us-east-1:123456789012:stack/unicorns/1"
+	testLogicalID = "MyUnicorn"
+	testRegion    = "us-east-1"
+	testAccountID = "123456789012"
+)
+
+// isolate runs the test with only the default configuration,
+// whatever the environment of the test binary, and discards the handler logs.
+func isolate(t *testing.T) {
+	t.Helper()
+	t.Setenv(EnvConfigFile, filepath.Join(t.TempDir(), ConfigFileName))
+	for _, env := range []string{
+		EnvEndpoint, EnvCollection, EnvAPIKey, EnvAPIKeyParameter, EnvAPIKeySecret,
+		EnvMode, EnvStabilizationTimeout, EnvInvocationTimeout, EnvRequestTimeout,
+		EnvMaxRetries, EnvPageSize, EnvConfirmDelete, EnvTracing, EnvTracingEndpoint,
+	} {
+		t.Setenv(env, "")
+	}
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+}
+
+// useMemoryStore points the handlers at an empty MemoryStore for the duration of the test.
+func useMemoryStore(t *testing.T) *MemoryStore {
+	t.Helper()
+	isolate(t)
+	store := NewMemoryStore()
+	Store = store
+	t.Cleanup(func() { Store = nil })
+	return store
+}
+
+// newRequest returns a request from the test stack.
+func newRequest() handler.Request {
+	req := handler.NewRequest("request", nil, handler.RequestContext{
+		StackID:   testStackID,
+		Region:    testRegion,
+		AccountID: testAccountID,
+	}, nil, nil, nil, nil)
+	req.LogicalResourceID = testLogicalID
+	return req
+}
+
+func newModel(name, color string) *Model {
+	return &Model{Name: aws.String(name), Color: aws.String(color)}
+}
+
+// mustCreate creates a unicorn and returns the model Create reported.
+func mustCreate(t *testing.T, model *Model) *Model {
+	t.Helper()
+	req := newRequest()
+	req.LogicalResourceID = aws.StringValue(model.Name)
+	event, err := Create(req, &Model{}, model)
+	if err != nil || event.OperationStatus != handler.Success {
+		t.Fatalf("Create(%s) = %s %s, %v", aws.StringValue(model.Name), event.OperationStatus, event.Message, err)
+	}
+	return event.ResourceModel.(*Model)
+}
+
+// checkEvent fails the test unless event has the wanted status and, when failed, error code.
+func checkEvent(t *testing.T, event handler.ProgressEvent, err error, status handler.Status, code string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+	if event.OperationStatus != status || event.HandlerErrorCode != code {
+		t.Fatalf("event = %s %q (%s), want %s %q", event.OperationStatus, event.HandlerErrorCode, event.Message, status, code)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name   string
+		seed   []*Model
+		model  *Model
+		status handler.Status
+		code   string
+	}{
+		{
+			name:   "new unicorn",
+			model:  newModel("Sparkles", "Pink"),
+			status: handler.Success,
+		},
+		{
+			name:   "invalid color",
+			model:  newModel("Sparkles", "Plaid"),
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeInvalidRequest,
+		},
+		{
+			name:   "missing name",
+			model:  &Model{Color: aws.String("Pink")},
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeInvalidRequest,
+		},
+		{
+			name:   "name taken",
+			seed:   []*Model{newModel("Sparkles", "Gold")},
+			model:  newModel("Sparkles", "Pink"),
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeAlreadyExists,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useMemoryStore(t)
+			for _, m := range tt.seed {
+				mustCreate(t, m)
+			}
+
+			event, err := Create(newRequest(), &Model{}, tt.model)
+			checkEvent(t, event, err, tt.status, tt.code)
+			if tt.status != handler.Success {
+				return
+			}
+			got := event.ResourceModel.(*Model)
+			if got.UID == nil {
+				t.Fatal("Create did not set UID")
+			}
+			u, err := store.Get(aws.StringValue(got.UID))
+			if err != nil {
+				t.Fatalf("Get(%s) error = %v", aws.StringValue(got.UID), err)
+			}
+			if u.Name != aws.StringValue(tt.model.Name) || u.Version != 1 {
+				t.Errorf("stored %+v, want name %s at version 1", u, aws.StringValue(tt.model.Name))
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name   string
+		model  func(created *Model) *Model
+		status handler.Status
+		code   string
+	}{
+		{
+			name:   "by UID",
+			model:  func(created *Model) *Model { return &Model{UID: created.UID} },
+			status: handler.Success,
+		},
+		{
+			name:   "by Name",
+			model:  func(*Model) *Model { return &Model{Name: aws.String("Sparkles")} },
+			status: handler.Success,
+		},
+		{
+			name:   "unknown UID",
+			model:  func(*Model) *Model { return &Model{UID: aws.String("missing")} },
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeNotFound,
+		},
+		{
+			name:   "unknown Name",
+			model:  func(*Model) *Model { return &Model{Name: aws.String("Glitter")} },
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeNotFound,
+		},
+		{
+			name:   "no identifier",
+			model:  func(*Model) *Model { return &Model{} },
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemoryStore(t)
+			created := mustCreate(t, newModel("Sparkles", "Pink"))
+
+			event, err := Read(newRequest(), &Model{}, tt.model(created))
+			checkEvent(t, event, err, tt.status, tt.code)
+			if tt.status != handler.Success {
+				return
+			}
+			got := event.ResourceModel.(*Model)
+			if aws.StringValue(got.UID) != aws.StringValue(created.UID) || aws.StringValue(got.Color) != "Pink" {
+				t.Errorf("Read() = %s %s, want %s Pink",
+					aws.StringValue(got.UID), aws.StringValue(got.Color), aws.StringValue(created.UID))
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  func(current *Model)
+		status  handler.Status
+		code    string
+		version int
+	}{
+		{
+			name:    "change color",
+			update:  func(m *Model) { m.Color = aws.String("Gold") },
+			status:  handler.Success,
+			version: 2,
+		},
+		{
+			name:    "nothing changed",
+			update:  func(*Model) {},
+			status:  handler.Success,
+			version: 1,
+		},
+		{
+			name:   "create-only property",
+			update: func(m *Model) { m.Species = aws.String("Pegasus") },
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeNotUpdatable,
+		},
+		{
+			name:   "unknown UID",
+			update: func(m *Model) { m.UID = aws.String("missing"); m.Color = aws.String("Gold") },
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeNotFound,
+		},
+		{
+			name:   "stale version",
+			update: func(m *Model) { m.Version = aws.Int(7); m.Color = aws.String("Gold") },
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeResourceConflict,
+		},
+		{
+			name:   "name taken",
+			update: func(m *Model) { m.Name = aws.String("Glitter") },
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeAlreadyExists,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useMemoryStore(t)
+			mustCreate(t, newModel("Glitter", "Silver"))
+			prev := mustCreate(t, newModel("Sparkles", "Pink"))
+			current := *prev
+			tt.update(&current)
+
+			event, err := Update(newRequest(), prev, &current)
+			checkEvent(t, event, err, tt.status, tt.code)
+			if tt.status != handler.Success {
+				return
+			}
+			u, err := store.Get(aws.StringValue(prev.UID))
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if u.Color != aws.StringValue(current.Color) || u.Version != tt.version {
+				t.Errorf("stored %s at version %d, want %s at version %d",
+					u.Color, u.Version, aws.StringValue(current.Color), tt.version)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		model  func(created *Model) *Model
+		status handler.Status
+		code   string
+	}{
+		{
+			name:   "existing unicorn",
+			model:  func(created *Model) *Model { return created },
+			status: handler.Success,
+		},
+		{
+			name:   "unknown UID",
+			model:  func(*Model) *Model { return &Model{UID: aws.String("missing")} },
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeNotFound,
+		},
+		{
+			name:   "no UID",
+			model:  func(*Model) *Model { return &Model{} },
+			status: handler.Failed,
+			code:   cloudformation.HandlerErrorCodeNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useMemoryStore(t)
+			created := mustCreate(t, newModel("Sparkles", "Pink"))
+
+			event, err := Delete(newRequest(), &Model{}, tt.model(created))
+			checkEvent(t, event, err, tt.status, tt.code)
+			if tt.status != handler.Success {
+				return
+			}
+			if _, err := store.Get(aws.StringValue(created.UID)); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+			}
+			event, err = Delete(newRequest(), &Model{}, created)
+			checkEvent(t, event, err, handler.Failed, cloudformation.HandlerErrorCodeNotFound)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		name     string
+		unicorns []string
+		pageSize string
+		pages    []int
+	}{
+		{
+			name:  "empty",
+			pages: []int{0},
+		},
+		{
+			name:     "single page",
+			unicorns: []string{"Sparkles", "Glitter", "Stardust"},
+			pages:    []int{3},
+		},
+		{
+			name:     "several pages",
+			unicorns: []string{"Sparkles", "Glitter", "Stardust"},
+			pageSize: "2",
+			pages:    []int{2, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemoryStore(t)
+			t.Setenv(EnvPageSize, tt.pageSize)
+			want := map[string]bool{}
+			for _, name := range tt.unicorns {
+				want[aws.StringValue(mustCreate(t, newModel(name, "Pink")).UID)] = true
+			}
+
+			req := newRequest()
+			for i, size := range tt.pages {
+				event, err := List(req, &Model{}, &Model{})
+				checkEvent(t, event, err, handler.Success, "")
+				if len(event.ResourceModels) != size {
+					t.Fatalf("page %d has %d models, want %d", i, len(event.ResourceModels), size)
+				}
+				// CloudFormation requires [] rather than null for an empty page.
+				if models := mustJSON(t, event.ResourceModels); size == 0 && models != "[]" {
+					t.Errorf("page %d: ResourceModels = %s, want []", i, models)
+				}
+				for _, m := range event.ResourceModels {
+					uid := aws.StringValue(m.(*Model).UID)
+					if !want[uid] {
+						t.Errorf("page %d: unexpected or repeated UID %s", i, uid)
+					}
+					delete(want, uid)
+					// List returns only the primary identifier.
+					if !reflect.DeepEqual(m, &Model{UID: aws.String(uid)}) {
+						t.Errorf("page %d: model = %s, want only the UID", i, mustJSON(t, m))
+					}
+				}
+				if last := i == len(tt.pages)-1; last != (event.NextToken == "") {
+					t.Fatalf("page %d: NextToken = %q", i, event.NextToken)
+				}
+				req.RequestContext.NextToken = event.NextToken
+			}
+			if len(want) != 0 {
+				t.Errorf("List() missed %d unicorns", len(want))
+			}
+		})
+	}
+}
+
+func TestUpdateStackTags(t *testing.T) {
+	tests := []struct {
+		name      string
+		color     string
+		stackTags map[string]string
+		want      map[string]string
+		version   int
+	}{
+		{
+			name:    "no stack tags and nothing else changed",
+			want:    map[string]string{"team": "sparkle"},
+			version: 1,
+		},
+		{
+			name:    "stack tags removed",
+			color:   "Gold",
+			version: 2,
+		},
+		{
+			name:      "same stack tags",
+			stackTags: map[string]string{"team": "sparkle"},
+			want:      map[string]string{"team": "sparkle"},
+			version:   1,
+		},
+		{
+			name:      "changed stack tags",
+			stackTags: map[string]string{"team": "glitter"},
+			want:      map[string]string{"team": "glitter"},
+			version:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useMemoryStore(t)
+			req := newRequest()
+			req.RequestContext.StackTags = map[string]string{"team": "sparkle"}
+			event, err := Create(req, &Model{}, newModel("Sparkles", "Pink"))
+			checkEvent(t, event, err, handler.Success, "")
+			prev := event.ResourceModel.(*Model)
+			current := *prev
+			if tt.color != "" {
+				current.Color = aws.String(tt.color)
+			}
+
+			req = newRequest()
+			req.RequestContext.StackTags = tt.stackTags
+			event, err = Update(req, prev, &current)
+			checkEvent(t, event, err, handler.Success, "")
+			u, err := store.Get(aws.StringValue(prev.UID))
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if !reflect.DeepEqual(u.StackTags, tt.want) || u.Version != tt.version {
+				t.Errorf("stored stack tags %v at version %d, want %v at version %d", u.StackTags, u.Version, tt.want, tt.version)
+			}
+		})
+	}
+}
+
+func TestUpdateUnchangedRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		stackTags map[string]string
+		requests  int
+	}{
+		{name: "no stack tags", requests: 0},
+		// The stored stack tags are read to compare them.
+		{name: "stack tags", stackTags: map[string]string{"team": "sparkle"}, requests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			captureMetrics(t)
+			var requests int
+			backend := fakecrud.NewServer()
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				backend.ServeHTTP(w, r)
+			}))
+			defer srv.Close()
+			t.Setenv(EnvEndpoint, srv.URL)
+			t.Setenv(EnvAPIKey, "0123456789abcdef0123456789abcdef")
+
+			req := newRequest()
+			req.RequestContext.StackTags = map[string]string{"team": "sparkle"}
+			event, err := Create(req, &Model{}, newModel("Sparkles", "Pink"))
+			checkEvent(t, event, err, handler.Success, "")
+			prev := event.ResourceModel.(*Model)
+			current := *prev
+
+			requests = 0
+			req = newRequest()
+			req.RequestContext.StackTags = tt.stackTags
+			event, err = Update(req, prev, &current)
+			checkEvent(t, event, err, handler.Success, "")
+			if requests != tt.requests {
+				t.Errorf("Update() made %d backend requests, want %d", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestCreateReadRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		model *Model
+		want  *Model
+	}{
+		{
+			name:  "required properties only",
+			model: newModel("Sparkles", "Pink"),
+			want:  newModel("Sparkles", "Pink"),
+		},
+		{
+			name: "zero values",
+			model: &Model{
+				Name:       aws.String("Sparkles"),
+				Color:      aws.String("Pink"),
+				Age:        aws.Int(0),
+				HornLength: aws.Float64(0),
+				HasWings:   aws.Bool(false),
+			},
+			want: &Model{
+				Name:       aws.String("Sparkles"),
+				Color:      aws.String("Pink"),
+				Age:        aws.Int(0),
+				HornLength: aws.Float64(0),
+				HasWings:   aws.Bool(false),
+			},
+		},
+		{
+			name: "all properties",
+			model: &Model{
+				Name:       aws.String("Sparkles"),
+				Color:      aws.String("Rainbow"),
+				Species:    aws.String("Pegasus"),
+				Age:        aws.Int(7),
+				HornLength: aws.Float64(12.5),
+				HasWings:   aws.Bool(true),
+				Abilities:  []string{"flight", "healing"},
+			},
+			want: &Model{
+				Name:       aws.String("Sparkles"),
+				Color:      aws.String("Rainbow"),
+				Species:    aws.String("Pegasus"),
+				Age:        aws.Int(7),
+				HornLength: aws.Float64(12.5),
+				HasWings:   aws.Bool(true),
+				Abilities:  []string{"flight", "healing"},
+			},
+		},
+		{
+			name: "tags",
+			model: &Model{
+				Name:  aws.String("Sparkles"),
+				Color: aws.String("Pink"),
+				Tags: []Tag{
+					{Key: aws.String("team"), Value: aws.String("sparkle")},
+					{Key: aws.String("env"), Value: aws.String("")},
+				},
+			},
+			want: &Model{
+				Name:  aws.String("Sparkles"),
+				Color: aws.String("Pink"),
+				Tags: []Tag{
+					{Key: aws.String("env"), Value: aws.String("")},
+					{Key: aws.String("team"), Value: aws.String("sparkle")},
+				},
+			},
+		},
+		{
+			name: "stable",
+			model: &Model{
+				Name:   aws.String("Sparkles"),
+				Color:  aws.String("Pink"),
+				Stable: &Stable{Name: aws.String(" Cloud Nine "), Region: aws.String("us-east-1")},
+			},
+			want: &Model{
+				Name:   aws.String("Sparkles"),
+				Color:  aws.String("Pink"),
+				Stable: &Stable{Name: aws.String("Cloud Nine"), Region: aws.String("us-east-1")},
+			},
+		},
+		{
+			name:  "palette color",
+			model: newModel(" Sparkles ", " pINK "),
+			want:  newModel("Sparkles", "Pink"),
+		},
+		{
+			name:  "hex color",
+			model: newModel("Sparkles", "#ff00aa"),
+			want:  newModel("Sparkles", "#FF00AA"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemoryStore(t)
+			event, err := Create(newRequest(), &Model{}, tt.model)
+			checkEvent(t, event, err, handler.Success, "")
+			created := event.ResourceModel.(*Model)
+
+			event, err = Read(newRequest(), &Model{}, &Model{UID: created.UID})
+			checkEvent(t, event, err, handler.Success, "")
+			read := event.ResourceModel.(*Model)
+			if !reflect.DeepEqual(read, created) {
+				t.Errorf("Read() = %s, want what Create returned, %s", mustJSON(t, read), mustJSON(t, created))
+			}
+
+			// Read-only properties are compared separately; the rest must be as given.
+			if read.Arn == nil || read.CreatedAt == nil || read.UpdatedAt == nil || aws.IntValue(read.Version) != 1 {
+				t.Errorf("Read() = %s, want read-only properties set", mustJSON(t, read))
+			}
+			want := *tt.want
+			want.UID, want.Arn, want.CreatedAt, want.UpdatedAt, want.Version = read.UID, read.Arn, read.CreatedAt, read.UpdatedAt, read.Version
+			if !reflect.DeepEqual(read, &want) {
+				t.Errorf("Read() = %s, want %s", mustJSON(t, read), mustJSON(t, &want))
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
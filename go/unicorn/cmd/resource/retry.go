@@ -0,0 +1,100 @@
+package resource
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how CrudCrudStore retries failed requests.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// BaseDelay is the backoff ceiling before the first retry; it doubles with every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff ceiling.
+	MaxDelay time.Duration
+	// RequestTimeout bounds a single attempt.
+	RequestTimeout time.Duration
+}
+
+// DefaultRetryPolicy is used by stores that are not given a policy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	BaseDelay:      200 * time.Millisecond,
+	MaxDelay:       5 * time.Second,
+	RequestTimeout: 10 * time.Second,
+}
+
+// httpClient is shared by every CrudCrudStore so connections are reused
+// across requests and across invocations of a warm Lambda.
+// Per-request timeouts are applied through the request context.
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   10,
+	},
+}
+
+// backoff returns a random delay in [0, min(MaxDelay, BaseDelay*2^retry)),
+// the "full jitter" strategy.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	ceiling := p.BaseDelay << uint(retry)
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// retryableStatus reports whether a response status is worth retrying.
+// Throttling and unavailability mean the request was not processed,
+// so they are retried for every method; other server errors only for
+// idempotent methods, since a POST may already have created the unicorn.
+func retryableStatus(method string, status int) bool {
+	switch {
+	case status == http.StatusTooManyRequests, status == http.StatusServiceUnavailable:
+		return true
+	case status >= 500:
+		return idempotent(method)
+	}
+	return false
+}
+
+// retryableError reports whether a transport error is worth retrying.
+func retryableError(method string, err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		// The connection was never made, so nothing was sent.
+		return true
+	}
+	if !idempotent(method) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		(errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
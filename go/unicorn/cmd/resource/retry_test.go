@@ -0,0 +1,194 @@
+package resource
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+func TestMakeRequestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		status   int
+		attempts int
+		code     string
+	}{
+		{name: "POST 500", method: http.MethodPost, status: 500, attempts: 1, code: cloudformation.HandlerErrorCodeServiceInternalError},
+		{name: "POST 429", method: http.MethodPost, status: 429, attempts: 4, code: cloudformation.HandlerErrorCodeThrottling},
+		{name: "POST 503", method: http.MethodPost, status: 503, attempts: 4, code: cloudformation.HandlerErrorCodeServiceInternalError},
+		{name: "GET 500", method: http.MethodGet, status: 500, attempts: 4, code: cloudformation.HandlerErrorCodeServiceInternalError},
+		{name: "GET 429", method: http.MethodGet, status: 429, attempts: 4, code: cloudformation.HandlerErrorCodeThrottling},
+		{name: "GET 404", method: http.MethodGet, status: 404, attempts: 1, code: cloudformation.HandlerErrorCodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if r.Method != tt.method {
+					t.Errorf("method = %s, want %s", r.Method, tt.method)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			store := NewCrudCrudStore(srv.URL + "/unicorns")
+			store.Retry = testRetryPolicy
+
+			var err error
+			if tt.method == http.MethodPost {
+				_, err = store.Create(&Unicorn{Name: "Sparkles", Color: "Pink"})
+			} else {
+				_, err = store.Get("1")
+			}
+			var serr *StoreError
+			if !errors.As(err, &serr) || serr.Code != tt.code {
+				t.Fatalf("error = %v, want a %s StoreError", err, tt.code)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("made %d attempts, want %d", attempts, tt.attempts)
+			}
+			// Only exhausted retries report the number of attempts.
+			if tt.attempts > 1 && !strings.Contains(serr.Message, "after 4 attempts") {
+				t.Errorf("message = %q, want the number of attempts", serr.Message)
+			}
+		})
+	}
+}
+
+func TestMakeRequestDeadline(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		// Hold the request until the client gives up at the deadline.
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	store := NewCrudCrudStore(srv.URL + "/unicorns")
+	store.Retry = testRetryPolicy
+	store.Deadline = time.Now().Add(50 * time.Millisecond)
+
+	// The timeout is retryable for a GET, but no time is left to retry.
+	_, err := store.Get("1")
+	var serr *StoreError
+	if !errors.As(err, &serr) || serr.Code != cloudformation.HandlerErrorCodeNetworkFailure {
+		t.Fatalf("Get() error = %v, want a NetworkFailure StoreError", err)
+	}
+	if attempts != 1 || !strings.Contains(serr.Message, "after 1 attempt") {
+		t.Errorf("made %d attempts with message %q, want 1", attempts, serr.Message)
+	}
+}
+
+func TestHasTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline time.Time
+		delay    time.Duration
+		want     bool
+	}{
+		{name: "no deadline", delay: time.Hour, want: true},
+		{name: "time left", deadline: time.Now().Add(time.Minute), delay: time.Second, want: true},
+		{name: "delay past the deadline", deadline: time.Now().Add(time.Second), delay: time.Minute},
+		{name: "deadline passed", deadline: time.Now().Add(-time.Second)},
+	}
+	for _, tt := range tests {
+		s := &CrudCrudStore{Deadline: tt.deadline}
+		if got := s.hasTime(tt.delay); got != tt.want {
+			t.Errorf("%s: hasTime(%s) = %v, want %v", tt.name, tt.delay, got, tt.want)
+		}
+	}
+}
+
+func TestRetryableStatus(t *testing.T) {
+	tests := []struct {
+		method string
+		status int
+		want   bool
+	}{
+		{http.MethodGet, 200, false},
+		{http.MethodGet, 400, false},
+		{http.MethodGet, 404, false},
+		{http.MethodGet, 429, true},
+		{http.MethodGet, 500, true},
+		{http.MethodGet, 502, true},
+		{http.MethodGet, 503, true},
+		{http.MethodPut, 500, true},
+		{http.MethodDelete, 504, true},
+		{http.MethodPost, 429, true},
+		{http.MethodPost, 500, false},
+		{http.MethodPost, 502, false},
+		{http.MethodPost, 503, true},
+	}
+	for _, tt := range tests {
+		if got := retryableStatus(tt.method, tt.status); got != tt.want {
+			t.Errorf("retryableStatus(%s, %d) = %v, want %v", tt.method, tt.status, got, tt.want)
+		}
+	}
+}
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryableError(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	read := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		want   bool
+	}{
+		{name: "dial GET", method: http.MethodGet, err: dial, want: true},
+		{name: "dial POST", method: http.MethodPost, err: dial, want: true},
+		{name: "reset GET", method: http.MethodGet, err: read, want: true},
+		{name: "reset POST", method: http.MethodPost, err: read},
+		{name: "EOF PUT", method: http.MethodPut, err: io.EOF, want: true},
+		{name: "unexpected EOF DELETE", method: http.MethodDelete, err: io.ErrUnexpectedEOF, want: true},
+		{name: "EOF POST", method: http.MethodPost, err: io.EOF},
+		{name: "timeout GET", method: http.MethodGet, err: timeoutError{}, want: true},
+		{name: "timeout POST", method: http.MethodPost, err: timeoutError{}},
+		{name: "other GET", method: http.MethodGet, err: errors.New("tls: bad certificate")},
+	}
+	for _, tt := range tests {
+		if got := retryableError(tt.method, tt.err); got != tt.want {
+			t.Errorf("%s: retryableError() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		retry   int
+		ceiling time.Duration
+	}{
+		{retry: 0, ceiling: 100 * time.Millisecond},
+		{retry: 1, ceiling: 200 * time.Millisecond},
+		{retry: 3, ceiling: 800 * time.Millisecond},
+		{retry: 4, ceiling: time.Second},
+		// The shift overflows; the ceiling is still MaxDelay.
+		{retry: 70, ceiling: time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := p.backoff(tt.retry); d < 0 || d >= tt.ceiling {
+				t.Fatalf("backoff(%d) = %s, want a delay in [0, %s)", tt.retry, d, tt.ceiling)
+			}
+		}
+	}
+	if d := (RetryPolicy{}).backoff(2); d != 0 {
+		t.Errorf("backoff() without delays = %s, want 0", d)
+	}
+}
@@ -0,0 +1,392 @@
+package resource
+
+//go:generate go run schema_gen.go
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// schema is the subset of JSON Schema used by resource schemas.
+type schema struct {
+	Type                 string             `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Definitions          map[string]*schema `json:"definitions"`
+	Ref                  string             `json:"$ref"`
+	AnyOf                []*schema          `json:"anyOf"`
+	Enum                 []interface{}      `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Items                *schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	UniqueItems          bool               `json:"uniqueItems"`
+	CreateOnlyProperties []string           `json:"createOnlyProperties"`
+
+	pattern *regexp.Regexp
+}
+
+// resourceSchema is the parsed resource schema. It is loaded when the
+// handler starts so a malformed schema fails fast.
+var resourceSchema = mustParseSchema(schemaJSON)
+
+func mustParseSchema(s string) *schema {
+	root := &schema{}
+	if err := json.Unmarshal([]byte(s), root); err != nil {
+		panic("resource schema: " + err.Error())
+	}
+	if err := root.compile(); err != nil {
+		panic("resource schema: " + err.Error())
+	}
+	return root
+}
+
+// compile compiles the patterns of s and everything below it.
+func (s *schema) compile() error {
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = re
+	}
+	children := []*schema{s.Items}
+	children = append(children, s.AnyOf...)
+	for _, c := range s.Properties {
+		children = append(children, c)
+	}
+	for _, c := range s.Definitions {
+		children = append(children, c)
+	}
+	for _, c := range children {
+		if c == nil {
+			continue
+		}
+		if err := c.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateModel validates the request against the resource schema and
+// returns an InvalidRequest event listing every problem, or nil.
+//
+// The plugin drops unknown properties and truncates fractions when it
+// decodes the request into the model, so the shape of the properties,
+// their names and types, is checked on the raw request first. The other
+// rules are checked on the normalized model, so " pink " is accepted as Pink.
+func validateModel(req handler.Request, model *Model) *handler.ProgressEvent {
+	errs := resourceSchema.validateRequest(req)
+	if len(errs) == 0 {
+		errs = resourceSchema.validateModel(model)
+	}
+	if len(errs) == 0 {
+		return validateTags(model)
+	}
+	return &handler.ProgressEvent{
+		OperationStatus:  handler.Failed,
+		HandlerErrorCode: cloudformation.HandlerErrorCodeInvalidRequest,
+		Message:          "Invalid properties: " + strings.Join(errs, "; "),
+	}
+}
+
+// validateRequest returns a message per property of the raw request
+// that is unknown or of the wrong type.
+func (s *schema) validateRequest(req handler.Request) []string {
+	body := resourceProperties(req)
+	if len(body) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return []string{err.Error()}
+	}
+	var errs []string
+	s.validateShape(s, "", v, &errs)
+	return errs
+}
+
+// resourceProperties returns the resource properties of the request as
+// CloudFormation sent them. The plugin keeps them in an unexported field
+// and only offers them decoded into the model.
+func resourceProperties(req handler.Request) []byte {
+	f := reflect.ValueOf(req).FieldByName("resourcePropertiesBody")
+	if f.Kind() != reflect.Slice {
+		return nil
+	}
+	return f.Bytes()
+}
+
+// validateModel returns a message per property of the model that breaks the schema.
+func (s *schema) validateModel(model *Model) []string {
+	b, err := json.Marshal(model)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return []string{err.Error()}
+	}
+	var errs []string
+	s.validate(s, "", v, &errs)
+	return errs
+}
+
+// validateShape appends a message to errs for every property of v that s
+// does not define and for every value that is not of the type s expects.
+func (s *schema) validateShape(root *schema, path string, v interface{}, errs *[]string) {
+	if s.Ref != "" {
+		if ref := root.resolve(s.Ref); ref != nil {
+			ref.validateShape(root, path, v, errs)
+		}
+		return
+	}
+	if s.Type != "" && !hasType(unstringify(v, s.Type), s.Type) {
+		prefix := path
+		if prefix == "" {
+			prefix = "Model"
+		}
+		*errs = append(*errs, fmt.Sprintf("%s: must be of type %s", prefix, s.Type))
+		return
+	}
+
+	switch v := v.(type) {
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validateShape(root, fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*errs = append(*errs, join(path, name)+": is not a known property")
+				}
+				continue
+			}
+			prop.validateShape(root, join(path, name), v[name], errs)
+		}
+	}
+}
+
+// validate appends a message to errs for every rule of s that v breaks.
+// root holds the definitions $ref points to.
+func (s *schema) validate(root *schema, path string, v interface{}, errs *[]string) {
+	prefix := path + ": "
+	if path == "" {
+		prefix = "Model: "
+	}
+	report := func(format string, args ...interface{}) {
+		*errs = append(*errs, prefix+fmt.Sprintf(format, args...))
+	}
+
+	if s.Ref != "" {
+		ref := root.resolve(s.Ref)
+		if ref == nil {
+			report("unknown reference %s", s.Ref)
+			return
+		}
+		ref.validate(root, path, v, errs)
+		return
+	}
+
+	if len(s.AnyOf) > 0 {
+		var reasons []string
+		for _, alt := range s.AnyOf {
+			var altErrs []string
+			alt.validate(root, path, v, &altErrs)
+			if len(altErrs) == 0 {
+				reasons = nil
+				break
+			}
+			for _, e := range altErrs {
+				reasons = append(reasons, strings.TrimPrefix(e, prefix))
+			}
+		}
+		if len(reasons) > 0 {
+			report("%s", strings.Join(reasons, ", or "))
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("must be one of %s", formatEnum(s.Enum))
+		}
+	}
+
+	if s.Type != "" && !hasType(v, s.Type) {
+		report("must be of type %s", s.Type)
+		return
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			report("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			report("must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			report("must match %s", s.Pattern)
+		}
+
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			report("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			report("must be at most %v", *s.Maximum)
+		}
+
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			report("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			report("must have at most %d items", *s.MaxItems)
+		}
+		if s.UniqueItems && !unique(v) {
+			report("must not contain duplicate items")
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(root, fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, join(path, name)+": is required")
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*errs = append(*errs, join(path, name)+": is not a known property")
+				}
+				continue
+			}
+			prop.validate(root, join(path, name), v[name], errs)
+		}
+	}
+}
+
+// resolve returns the definition a local $ref such as #/definitions/Tag points to.
+func (s *schema) resolve(ref string) *schema {
+	const prefix = "#/definitions/"
+	if !strings.HasPrefix(ref, prefix) {
+		return nil
+	}
+	return s.Definitions[strings.TrimPrefix(ref, prefix)]
+}
+
+// unstringify returns the value of type t that v holds as a string.
+// CloudFormation passes scalar properties as strings, e.g. "3" for 3.
+// Anything else is returned as is.
+func unstringify(v interface{}, t string) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	switch t {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+func hasType(v interface{}, t string) bool {
+	switch t {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	}
+	return true
+}
+
+func unique(items []interface{}) bool {
+	for i := range items {
+		for j := i + 1; j < len(items); j++ {
+			if reflect.DeepEqual(items[i], items[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func formatEnum(values []interface{}) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	return strings.Join(s, ", ")
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,44 @@
+//go:build ignore
+// +build ignore
+
+// schema_gen.go copies the resource schema into schema_json.go so the
+// handlers can validate models without reading files at runtime.
+// Run it with `go generate` after editing brianterry-unicorn-maker.json.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+const (
+	source = "../../brianterry-unicorn-maker.json"
+	target = "schema_json.go"
+)
+
+func main() {
+	b, err := ioutil.ReadFile(source)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !json.Valid(b) {
+		log.Fatalf("%s is not valid JSON", source)
+	}
+	if bytes.ContainsRune(b, '`') {
+		log.Fatalf("%s contains a backtick", source)
+	}
+
+	var out strings.Builder
+	out.WriteString("// Code generated by schema_gen.go from brianterry-unicorn-maker.json. DO NOT EDIT.\n\n")
+	out.WriteString("package resource\n\n")
+	out.WriteString("// schemaJSON is the resource schema, brianterry-unicorn-maker.json.\n")
+	out.WriteString("const schemaJSON = `")
+	out.Write(bytes.TrimSpace(b))
+	out.WriteString("`\n")
+	if err := ioutil.WriteFile(target, []byte(out.String()), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,116 @@
+package resource
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// requestWithProperties returns a request carrying the resource properties
+// as CloudFormation sends them, and the model the plugin decodes from them.
+func requestWithProperties(t *testing.T, properties string) (handler.Request, *Model) {
+	t.Helper()
+	req := handler.NewRequest("request", nil, handler.RequestContext{
+		StackID:   testStackID,
+		Region:    testRegion,
+		AccountID: testAccountID,
+	}, nil, nil, []byte(properties), nil)
+	req.LogicalResourceID = testLogicalID
+	model := &Model{}
+	if err := req.Unmarshal(model); err != nil {
+		t.Fatalf("Unmarshal(%s) error = %v", properties, err)
+	}
+	return req, model
+}
+
+func TestValidateModel(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties string
+		want       []string
+	}{
+		{
+			name:       "valid",
+			properties: `{"Name":"Sparkles","Color":"pink","Age":"3","HornLength":"12.5","HasWings":"true"}`,
+		},
+		{
+			name:       "unknown property",
+			properties: `{"Name":"Sparkles","Color":"pink","Colour":"blue"}`,
+			want:       []string{"Colour: is not a known property"},
+		},
+		{
+			name:       "unknown nested property",
+			properties: `{"Name":"Sparkles","Color":"Pink","Stable":{"Name":"Cloud Nine","Size":"big"},"Tags":[{"Key":"a","Value":"b","Note":"c"}]}`,
+			want:       []string{"Stable.Size: is not a known property", "Tags[0].Note: is not a known property"},
+		},
+		{
+			name:       "fractional integer",
+			properties: `{"Name":"Sparkles","Color":"Pink","Age":3.7}`,
+			want:       []string{"Age: must be of type integer"},
+		},
+		{
+			name:       "too short",
+			properties: `{"Name":"Sp","Color":"Pink"}`,
+			want:       []string{"Name: must be at least 3 characters"},
+		},
+		{
+			name:       "out of range",
+			properties: `{"Name":"Sparkles","Color":"Pink","Age":"-1"}`,
+			want:       []string{"Age: must be at least 0"},
+		},
+		{
+			name:       "not in palette",
+			properties: `{"Name":"Sparkles","Color":"Plaid"}`,
+			want:       []string{"Color: must be one of Pink, Rainbow, Silver, Gold, White, Black, Purple, Blue, or must match ^#[0-9A-Fa-f]{6}$"},
+		},
+		{
+			name:       "required",
+			properties: `{"Color":"Pink"}`,
+			want:       []string{"Name: is required"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, model := requestWithProperties(t, tt.properties)
+			normalize(model)
+
+			event := validateModel(req, model)
+			if len(tt.want) == 0 {
+				if event != nil {
+					t.Fatalf("validateModel() = %s", event.Message)
+				}
+				return
+			}
+			if event == nil {
+				t.Fatalf("validateModel() = nil, want %q", tt.want)
+			}
+			if event.HandlerErrorCode != cloudformation.HandlerErrorCodeInvalidRequest {
+				t.Errorf("HandlerErrorCode = %s, want InvalidRequest", event.HandlerErrorCode)
+			}
+			if want := "Invalid properties: " + strings.Join(tt.want, "; "); event.Message != want {
+				t.Errorf("Message = %q, want %q", event.Message, want)
+			}
+		})
+	}
+}
+
+func TestCreateRejectsUnknownProperties(t *testing.T) {
+	store := useMemoryStore(t)
+	req, model := requestWithProperties(t, `{"Name":"Sparkles","Color":"pink","Colour":"blue"}`)
+
+	event, err := Create(req, &Model{}, model)
+	checkEvent(t, event, err, handler.Failed, cloudformation.HandlerErrorCodeInvalidRequest)
+	if unicorns, _, _ := store.List("", 10); len(unicorns) != 0 {
+		t.Errorf("Create stored %d unicorns, want none", len(unicorns))
+	}
+
+	req, model = requestWithProperties(t, `{"Name":"Sparkles","Color":"pink","Age":"3"}`)
+	event, err = Create(req, &Model{}, model)
+	checkEvent(t, event, err, handler.Success, "")
+	if got := event.ResourceModel.(*Model); aws.StringValue(got.Color) != "Pink" || aws.IntValue(got.Age) != 3 {
+		t.Errorf("Create() = %s %d, want Pink 3", aws.StringValue(got.Color), aws.IntValue(got.Age))
+	}
+}
@@ -0,0 +1,151 @@
+package resource
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// secretTTL is how long a fetched secret is reused by a warm Lambda
+// before it is fetched again, so rotated keys are eventually picked up.
+const secretTTL = 5 * time.Minute
+
+// The clients used to fetch secrets. They are variables so they
+// can be replaced by fakes of the AWS APIs.
+var (
+	newSSMClient = func(sess *session.Session) ssmiface.SSMAPI {
+		return ssm.New(sess)
+	}
+	newSecretsManagerClient = func(sess *session.Session) secretsmanageriface.SecretsManagerAPI {
+		return secretsmanager.New(sess)
+	}
+)
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// secretCache holds secrets across invocations of a warm Lambda.
+var secretCache = struct {
+	sync.Mutex
+	values map[string]cachedSecret
+}{values: map[string]cachedSecret{}}
+
+// resolveAPIKey fills in c.APIKey from SSM Parameter Store or Secrets Manager
+// when it is not set directly, using the caller credentials of the request.
+func (c *Config) resolveAPIKey(req handler.Request) error {
+	if c.APIKey != "" {
+		return nil
+	}
+	var (
+		source string
+		fetch  func() (string, error)
+	)
+	switch {
+	case c.APIKeyParameter != "":
+		source = "ssm:" + c.APIKeyParameter
+		fetch = func() (string, error) {
+			return getParameter(newSSMClient(req.Session), c.APIKeyParameter)
+		}
+	case c.APIKeySecret != "":
+		source = "secretsmanager:" + c.APIKeySecret
+		fetch = func() (string, error) {
+			return getSecret(newSecretsManagerClient(req.Session), c.APIKeySecret)
+		}
+	default:
+		return ErrNotConfigured
+	}
+	if req.Session == nil {
+		return &StoreError{
+			Code:    cloudformation.HandlerErrorCodeInvalidCredentials,
+			Message: "No AWS session available to read " + source,
+		}
+	}
+	// A warm Lambda serves every account and region the type is
+	// registered in, and the same name can hold a different key in each,
+	// or one the caller is not allowed to read.
+	key := req.RequestContext.AccountID + ":" + req.RequestContext.Region + ":" + source
+
+	secretCache.Lock()
+	defer secretCache.Unlock()
+	if s, ok := secretCache.values[key]; ok && time.Now().Before(s.expires) {
+		c.APIKey = s.value
+		return nil
+	}
+	value, err := fetch()
+	if err != nil {
+		return &StoreError{
+			Code:    fetchErrorCode(err),
+			Message: "Unable to read " + source + ": " + err.Error(),
+		}
+	}
+	secretCache.values[key] = cachedSecret{
+		value:   value,
+		expires: time.Now().Add(secretTTL),
+	}
+	c.APIKey = value
+	return nil
+}
+
+// fetchErrorCode maps an error of SSM or Secrets Manager to a handler error
+// code. Errors that are neither a missing secret, throttling nor a service
+// failure are about permissions: on the secret, its KMS key or the
+// credentials themselves.
+func fetchErrorCode(err error) string {
+	if request.IsErrorThrottle(err) {
+		return cloudformation.HandlerErrorCodeThrottling
+	}
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		switch aerr.Code() {
+		case ssm.ErrCodeParameterNotFound,
+			ssm.ErrCodeParameterVersionNotFound,
+			secretsmanager.ErrCodeResourceNotFoundException,
+			secretsmanager.ErrCodeInvalidRequestException:
+			return cloudformation.HandlerErrorCodeInvalidRequest
+		case ssm.ErrCodeInternalServerError,
+			secretsmanager.ErrCodeInternalServiceError:
+			return cloudformation.HandlerErrorCodeServiceInternalError
+		}
+	}
+	if request.IsErrorRetryable(err) {
+		return cloudformation.HandlerErrorCodeServiceInternalError
+	}
+	return cloudformation.HandlerErrorCodeAccessDenied
+}
+
+// getParameter reads a (possibly SecureString) parameter from SSM Parameter Store.
+func getParameter(client ssmiface.SSMAPI, name string) (string, error) {
+	out, err := client.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(aws.StringValue(out.Parameter.Value)), nil
+}
+
+// getSecret reads a string secret from Secrets Manager.
+func getSecret(client secretsmanageriface.SecretsManagerAPI, id string) (string, error) {
+	out, err := client.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(aws.StringValue(out.SecretString)), nil
+}
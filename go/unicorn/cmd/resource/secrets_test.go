@@ -0,0 +1,234 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// fakeSSM answers GetParameter with a new value on every call,
+// so a test can tell a fetched key from a cached one, or with err when set.
+type fakeSSM struct {
+	ssmiface.SSMAPI
+	calls int
+	err   error
+}
+
+func (f *fakeSSM) GetParameter(in *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &ssm.GetParameterOutput{
+		Parameter: &ssm.Parameter{
+			Name:  in.Name,
+			Value: aws.String(fmt.Sprintf("key-%d", f.calls)),
+		},
+	}, nil
+}
+
+// fakeSecretsManager answers GetSecretValue with value, or with err when set.
+type fakeSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	value string
+	err   error
+	ids   []string
+}
+
+func (f *fakeSecretsManager) GetSecretValue(in *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	f.ids = append(f.ids, aws.StringValue(in.SecretId))
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &secretsmanager.GetSecretValueOutput{
+		SecretString: aws.String(f.value),
+	}, nil
+}
+
+// resetSecretCache empties the secret cache now and when the test ends.
+func resetSecretCache(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		secretCache.Lock()
+		secretCache.values = map[string]cachedSecret{}
+		secretCache.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// useFakeSSM replaces the SSM client and empties the secret cache for the duration of the test.
+func useFakeSSM(t *testing.T) *fakeSSM {
+	t.Helper()
+	fake := &fakeSSM{}
+	newClient := newSSMClient
+	newSSMClient = func(*session.Session) ssmiface.SSMAPI { return fake }
+	t.Cleanup(func() { newSSMClient = newClient })
+	resetSecretCache(t)
+	return fake
+}
+
+// useFakeSecretsManager replaces the Secrets Manager client and empties the
+// secret cache for the duration of the test.
+func useFakeSecretsManager(t *testing.T) *fakeSecretsManager {
+	t.Helper()
+	fake := &fakeSecretsManager{}
+	newClient := newSecretsManagerClient
+	newSecretsManagerClient = func(*session.Session) secretsmanageriface.SecretsManagerAPI { return fake }
+	t.Cleanup(func() { newSecretsManagerClient = newClient })
+	resetSecretCache(t)
+	return fake
+}
+
+func TestResolveAPIKeyCachePerAccountAndRegion(t *testing.T) {
+	fake := useFakeSSM(t)
+	sess := session.Must(session.NewSession())
+	request := func(account, region string) handler.Request {
+		return handler.NewRequest("request", nil, handler.RequestContext{
+			AccountID: account,
+			Region:    region,
+		}, sess, nil, nil, nil)
+	}
+
+	tests := []struct {
+		name  string
+		req   handler.Request
+		want  string
+		calls int
+	}{
+		{name: "first fetch", req: request("111111111111", "us-east-1"), want: "key-1", calls: 1},
+		{name: "cached", req: request("111111111111", "us-east-1"), want: "key-1", calls: 1},
+		{name: "other account", req: request("222222222222", "us-east-1"), want: "key-2", calls: 2},
+		{name: "other region", req: request("111111111111", "eu-west-1"), want: "key-3", calls: 3},
+		{name: "still cached", req: request("111111111111", "us-east-1"), want: "key-1", calls: 3},
+	}
+	for _, tt := range tests {
+		cfg := &Config{APIKeyParameter: "/unicorns/api-key"}
+		if err := cfg.resolveAPIKey(tt.req); err != nil {
+			t.Fatalf("%s: resolveAPIKey() error = %v", tt.name, err)
+		}
+		if cfg.APIKey != tt.want || fake.calls != tt.calls {
+			t.Errorf("%s: APIKey = %s after %d calls, want %s after %d", tt.name, cfg.APIKey, fake.calls, tt.want, tt.calls)
+		}
+	}
+}
+
+func TestGetSecret(t *testing.T) {
+	fake := &fakeSecretsManager{value: " 0123456789abcdef\n"}
+	got, err := getSecret(fake, "unicorns/api-key")
+	if err != nil {
+		t.Fatalf("getSecret() error = %v", err)
+	}
+	if got != "0123456789abcdef" {
+		t.Errorf("getSecret() = %q, want the secret without surrounding white space", got)
+	}
+	if len(fake.ids) != 1 || fake.ids[0] != "unicorns/api-key" {
+		t.Errorf("GetSecretValue() called with %v, want unicorns/api-key", fake.ids)
+	}
+}
+
+func TestResolveAPIKeyErrors(t *testing.T) {
+	sess := session.Must(session.NewSession())
+	req := handler.NewRequest("request", nil, handler.RequestContext{
+		AccountID: testAccountID,
+		Region:    testRegion,
+	}, sess, nil, nil, nil)
+
+	tests := []struct {
+		name string
+		cfg  Config
+		err  error
+		code string
+	}{
+		{
+			name: "parameter not found",
+			cfg:  Config{APIKeyParameter: "/unicorns/api-key"},
+			err:  awserr.New(ssm.ErrCodeParameterNotFound, "not found", nil),
+			code: cloudformation.HandlerErrorCodeInvalidRequest,
+		},
+		{
+			name: "secret not found",
+			cfg:  Config{APIKeySecret: "unicorns/api-key"},
+			err:  awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil),
+			code: cloudformation.HandlerErrorCodeInvalidRequest,
+		},
+		{
+			name: "parameter throttled",
+			cfg:  Config{APIKeyParameter: "/unicorns/api-key"},
+			err:  awserr.New("ThrottlingException", "rate exceeded", nil),
+			code: cloudformation.HandlerErrorCodeThrottling,
+		},
+		{
+			name: "secret throttled",
+			cfg:  Config{APIKeySecret: "unicorns/api-key"},
+			err:  awserr.New("ThrottlingException", "rate exceeded", nil),
+			code: cloudformation.HandlerErrorCodeThrottling,
+		},
+		{
+			name: "parameter access denied",
+			cfg:  Config{APIKeyParameter: "/unicorns/api-key"},
+			err:  awserr.New("AccessDeniedException", "not authorized", nil),
+			code: cloudformation.HandlerErrorCodeAccessDenied,
+		},
+		{
+			name: "secret key not decryptable",
+			cfg:  Config{APIKeySecret: "unicorns/api-key"},
+			err:  awserr.New(secretsmanager.ErrCodeDecryptionFailure, "kms denied", nil),
+			code: cloudformation.HandlerErrorCodeAccessDenied,
+		},
+		{
+			name: "secret service failure",
+			cfg:  Config{APIKeySecret: "unicorns/api-key"},
+			err:  awserr.New(secretsmanager.ErrCodeInternalServiceError, "internal", nil),
+			code: cloudformation.HandlerErrorCodeServiceInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeSSM(t).err = tt.err
+			useFakeSecretsManager(t).err = tt.err
+			cfg := tt.cfg
+			err := cfg.resolveAPIKey(req)
+			var serr *StoreError
+			if !errors.As(err, &serr) || serr.Code != tt.code {
+				t.Fatalf("resolveAPIKey() error = %v, want a %s StoreError", err, tt.code)
+			}
+			if cfg.APIKey != "" {
+				t.Errorf("APIKey = %q, want none", cfg.APIKey)
+			}
+		})
+	}
+}
+
+func TestResolveAPIKeyFromSecret(t *testing.T) {
+	fake := useFakeSecretsManager(t)
+	fake.value = "0123456789abcdef"
+	req := handler.NewRequest("request", nil, handler.RequestContext{
+		AccountID: testAccountID,
+		Region:    testRegion,
+	}, session.Must(session.NewSession()), nil, nil, nil)
+
+	for i := 0; i < 2; i++ {
+		cfg := &Config{APIKeySecret: "unicorns/api-key"}
+		if err := cfg.resolveAPIKey(req); err != nil {
+			t.Fatalf("resolveAPIKey() error = %v", err)
+		}
+		if cfg.APIKey != fake.value {
+			t.Errorf("APIKey = %q, want %q", cfg.APIKey, fake.value)
+		}
+	}
+	// The second call is served from the cache.
+	if len(fake.ids) != 1 {
+		t.Errorf("GetSecretValue() called %d times, want 1", len(fake.ids))
+	}
+}
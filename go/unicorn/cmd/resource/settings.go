@@ -0,0 +1,314 @@
+package resource
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/cfnerr"
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+const (
+	// DefaultEndpoint is the crudcrud API root used when none is configured.
+	DefaultEndpoint = "https://crudcrud.com/api"
+	// DefaultCollection is the crudcrud collection used when none is configured.
+	DefaultCollection = "unicorns"
+	// ConfigFileName is the name of the config file bundled next to the handler binary.
+	ConfigFileName = "config.json"
+	// DefaultStabilizationTimeout is how long callback mode waits for the backend to settle.
+	DefaultStabilizationTimeout = 10 * time.Minute
+	// DefaultInvocationTimeout is the time budget of a single handler invocation.
+	// Backend requests and retries are not started past it.
+	DefaultInvocationTimeout = 55 * time.Second
+)
+
+// Handler modes.
+const (
+	// ModeSync completes Create, Update and Delete in a single invocation.
+	ModeSync = "sync"
+	// ModeCallback returns IN_PROGRESS from Create, Update and Delete and
+	// polls the backend on each reinvocation until the change is visible.
+	ModeCallback = "callback"
+)
+
+// DefaultMode is the handler mode used when none is configured.
+// The callback build sets it with
+// -ldflags "-X github.com/brianterry/unicorn-maker/go/cmd/resource.DefaultMode=callback".
+var DefaultMode = ModeSync
+
+// Environment variables that override the config file.
+const (
+	EnvEndpoint             = "UNICORN_API_ENDPOINT"
+	EnvCollection           = "UNICORN_API_COLLECTION"
+	EnvAPIKey               = "UNICORN_API_KEY"
+	EnvAPIKeyParameter      = "UNICORN_API_KEY_PARAMETER"
+	EnvAPIKeySecret         = "UNICORN_API_KEY_SECRET"
+	EnvConfigFile           = "UNICORN_CONFIG_FILE"
+	EnvMode                 = "UNICORN_MODE"
+	EnvStabilizationTimeout = "UNICORN_STABILIZATION_TIMEOUT"
+	EnvInvocationTimeout    = "UNICORN_INVOCATION_TIMEOUT"
+	EnvRequestTimeout       = "UNICORN_REQUEST_TIMEOUT"
+	EnvMaxRetries           = "UNICORN_MAX_RETRIES"
+	EnvPageSize             = "UNICORN_PAGE_SIZE"
+	EnvConfirmDelete        = "UNICORN_CONFIRM_DELETE"
+	EnvTracing              = "UNICORN_TRACING"
+	EnvTracingEndpoint      = "UNICORN_TRACING_ENDPOINT"
+)
+
+// ErrNotConfigured is returned when no API key could be resolved.
+var ErrNotConfigured = &StoreError{
+	Code: cloudformation.HandlerErrorCodeInvalidRequest,
+	Message: "Backend is not configured: set " + EnvAPIKey + ", " + EnvAPIKeyParameter +
+		" or " + EnvAPIKeySecret + ", add them to " + ConfigFileName + " next to the handler binary," +
+		" or set the type configuration",
+}
+
+// Config holds the backend settings resolved at runtime.
+type Config struct {
+	// Endpoint is the API root, e.g. https://crudcrud.com/api
+	Endpoint string `json:"endpoint,omitempty"`
+	// Collection is the name of the collection the unicorns are stored in.
+	Collection string `json:"collection,omitempty"`
+	// APIKey is the crudcrud API key.
+	APIKey string `json:"apiKey,omitempty"`
+	// APIKeyParameter is the name of an SSM parameter holding the API key.
+	// It is used when APIKey is not set.
+	APIKeyParameter string `json:"apiKeyParameter,omitempty"`
+	// APIKeySecret is the ID of a Secrets Manager secret holding the API key.
+	// It is used when neither APIKey nor APIKeyParameter are set.
+	APIKeySecret string `json:"apiKeySecret,omitempty"`
+	// Mode is the handler mode, ModeSync or ModeCallback.
+	Mode string `json:"mode,omitempty"`
+	// StabilizationTimeout is how many seconds callback mode waits
+	// for a change to become visible before failing.
+	StabilizationTimeout int `json:"stabilizationTimeout,omitempty"`
+	// InvocationTimeout is the time budget of a single handler invocation, in seconds.
+	InvocationTimeout int `json:"invocationTimeout,omitempty"`
+	// RequestTimeout bounds a single backend request, in seconds.
+	RequestTimeout int `json:"requestTimeout,omitempty"`
+	// MaxRetries is how many times a failed backend request is retried.
+	// Nil means DefaultRetryPolicy.MaxRetries.
+	MaxRetries *int `json:"maxRetries,omitempty"`
+	// PageSize is the number of unicorns List returns per page.
+	PageSize int `json:"pageSize,omitempty"`
+	// ConfirmDelete makes Delete wait, as in callback mode, until
+	// the backend no longer returns the deleted unicorn.
+	ConfirmDelete bool `json:"confirmDelete,omitempty"`
+	// Tracing is the trace exporter: TracingNone, TracingStdout or TracingOTLP.
+	Tracing string `json:"tracing,omitempty"`
+	// TracingEndpoint is the OTLP/HTTP traces endpoint TracingOTLP sends to.
+	// It defaults to the standard OTEL_EXPORTER_OTLP_* variables,
+	// then to DefaultTracingEndpoint.
+	TracingEndpoint string `json:"tracingEndpoint,omitempty"`
+}
+
+// URL returns the collection URL, e.g. https://crudcrud.com/api/<API key>/unicorns
+func (c *Config) URL() string {
+	return strings.TrimRight(c.Endpoint, "/") + "/" + c.APIKey + "/" + c.Collection
+}
+
+// LoadConfig resolves the backend configuration for the request.
+// The type configuration set with SetTypeConfiguration takes precedence,
+// then the environment, then the config file, and the defaults are used
+// for anything left unset. When the API key is kept in SSM or Secrets
+// Manager it is fetched with the caller credentials in req.Session.
+func LoadConfig(req handler.Request) (*Config, error) {
+	cfg, err := readConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.readTypeConfiguration(req); err != nil {
+		return nil, err
+	}
+	if err := cfg.resolveAPIKey(req); err != nil {
+		return nil, err
+	}
+	addSecret(cfg.APIKey)
+	return cfg, nil
+}
+
+// readConfig merges the config file, the environment and the defaults,
+// without resolving the API key.
+func readConfig() (*Config, error) {
+	cfg := &Config{}
+	if err := cfg.readFile(configFilePath()); err != nil {
+		return nil, &StoreError{
+			Code:    cloudformation.HandlerErrorCodeInvalidRequest,
+			Message: "Unable to read config file: " + err.Error(),
+		}
+	}
+	cfg.readEnv()
+
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = DefaultEndpoint
+	}
+	if cfg.Collection == "" {
+		cfg.Collection = DefaultCollection
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = DefaultMode
+	}
+	if cfg.PageSize <= 0 {
+		cfg.PageSize = DefaultPageSize
+	}
+	if cfg.Tracing == "" {
+		cfg.Tracing = TracingNone
+	}
+	if cfg.TracingEndpoint == "" {
+		cfg.TracingEndpoint = otlpEndpointFromEnv()
+	}
+	return cfg, nil
+}
+
+// Deadline returns when an invocation that starts now runs out of time.
+func (c *Config) Deadline() time.Time {
+	timeout := DefaultInvocationTimeout
+	if c.InvocationTimeout > 0 {
+		timeout = time.Duration(c.InvocationTimeout) * time.Second
+	}
+	return time.Now().Add(timeout)
+}
+
+// RetryPolicy returns DefaultRetryPolicy with the configured overrides.
+func (c *Config) RetryPolicy() RetryPolicy {
+	p := DefaultRetryPolicy
+	if c.RequestTimeout > 0 {
+		p.RequestTimeout = time.Duration(c.RequestTimeout) * time.Second
+	}
+	if c.MaxRetries != nil && *c.MaxRetries >= 0 {
+		p.MaxRetries = *c.MaxRetries
+	}
+	return p
+}
+
+// Timeout returns how long callback mode waits for a change to become visible.
+func (c *Config) Timeout() time.Duration {
+	if c.StabilizationTimeout > 0 {
+		return time.Duration(c.StabilizationTimeout) * time.Second
+	}
+	return DefaultStabilizationTimeout
+}
+
+// configFilePath returns the path of the config file. Unless overridden
+// by the environment, it is the file next to the running binary.
+func configFilePath() string {
+	if p := os.Getenv(EnvConfigFile); p != "" {
+		return p
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(exe), ConfigFileName)
+}
+
+// readFile merges the config file into c. A missing file is not an error.
+func (c *Config) readFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, c)
+}
+
+// readTypeConfiguration merges the type configuration of the request into c.
+// A type without a configuration is not an error.
+func (c *Config) readTypeConfiguration(req handler.Request) error {
+	tc, err := Configuration(req)
+	var cerr cfnerr.Error
+	if errors.As(err, &cerr) && cerr.Code() == "BodyEmpty" {
+		return nil
+	}
+	if err != nil {
+		return &StoreError{
+			Code:    cloudformation.HandlerErrorCodeInvalidRequest,
+			Message: "Unable to read type configuration: " + err.Error(),
+		}
+	}
+	if v := aws.StringValue(tc.Endpoint); v != "" {
+		c.Endpoint = v
+	}
+	if v := aws.StringValue(tc.Collection); v != "" {
+		c.Collection = v
+	}
+	// A key source in the type configuration replaces the others,
+	// so a key bundled with the handler does not shadow it.
+	if tc.ApiKey != nil || tc.ApiKeyParameter != nil || tc.ApiKeySecret != nil {
+		c.APIKey = aws.StringValue(tc.ApiKey)
+		c.APIKeyParameter = aws.StringValue(tc.ApiKeyParameter)
+		c.APIKeySecret = aws.StringValue(tc.ApiKeySecret)
+	}
+	return nil
+}
+
+// readEnv merges the environment variables into c.
+func (c *Config) readEnv() {
+	if v := os.Getenv(EnvEndpoint); v != "" {
+		c.Endpoint = v
+	}
+	if v := os.Getenv(EnvCollection); v != "" {
+		c.Collection = v
+	}
+	if v := os.Getenv(EnvAPIKey); v != "" {
+		c.APIKey = v
+	}
+	if v := os.Getenv(EnvAPIKeyParameter); v != "" {
+		c.APIKeyParameter = v
+	}
+	if v := os.Getenv(EnvAPIKeySecret); v != "" {
+		c.APIKeySecret = v
+	}
+	if v := os.Getenv(EnvMode); v != "" {
+		c.Mode = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(EnvStabilizationTimeout)); err == nil {
+		c.StabilizationTimeout = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(EnvInvocationTimeout)); err == nil {
+		c.InvocationTimeout = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(EnvRequestTimeout)); err == nil {
+		c.RequestTimeout = v
+	}
+	if v, err := strconv.Atoi(os.Getenv(EnvMaxRetries)); err == nil {
+		c.MaxRetries = &v
+	}
+	if v, err := strconv.Atoi(os.Getenv(EnvPageSize)); err == nil {
+		c.PageSize = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv(EnvConfirmDelete)); err == nil {
+		c.ConfirmDelete = v
+	}
+	if v := os.Getenv(EnvTracing); v != "" {
+		c.Tracing = v
+	}
+	if v := os.Getenv(EnvTracingEndpoint); v != "" {
+		c.TracingEndpoint = v
+	}
+}
+
+// otlpEndpointFromEnv returns the traces endpoint set by the standard
+// OpenTelemetry variables, or DefaultTracingEndpoint.
+func otlpEndpointFromEnv() string {
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); v != "" {
+		return v
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		return strings.TrimRight(v, "/") + "/v1/traces"
+	}
+	return DefaultTracingEndpoint
+}
@@ -0,0 +1,69 @@
+package resource
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+)
+
+func TestLoadConfigTypeConfiguration(t *testing.T) {
+	t.Setenv(EnvConfigFile, filepath.Join(t.TempDir(), ConfigFileName))
+	t.Setenv(EnvEndpoint, "http://env.example")
+	t.Setenv(EnvCollection, "")
+	t.Setenv(EnvAPIKey, "env-key")
+	t.Setenv(EnvAPIKeyParameter, "")
+	t.Setenv(EnvAPIKeySecret, "")
+
+	tests := []struct {
+		name       string
+		typeConfig string
+		want       Config
+		wantErr    bool
+	}{
+		{
+			name: "no type configuration",
+			want: Config{Endpoint: "http://env.example", Collection: DefaultCollection, APIKey: "env-key"},
+		},
+		{
+			name:       "type configuration takes precedence",
+			typeConfig: `{"Endpoint":"http://type.example","Collection":"herd","ApiKey":"type-key"}`,
+			want:       Config{Endpoint: "http://type.example", Collection: "herd", APIKey: "type-key"},
+		},
+		{
+			name:       "endpoint only keeps the env key",
+			typeConfig: `{"Endpoint":"http://type.example"}`,
+			want:       Config{Endpoint: "http://type.example", Collection: DefaultCollection, APIKey: "env-key"},
+		},
+		{
+			name:       "malformed type configuration",
+			typeConfig: `{"Endpoint":`,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var typeConfig []byte
+			if tt.typeConfig != "" {
+				typeConfig = []byte(tt.typeConfig)
+			}
+			req := handler.NewRequest("id", nil, handler.RequestContext{}, nil, nil, nil, typeConfig)
+
+			cfg, err := LoadConfig(req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LoadConfig() = %+v, want error", cfg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if cfg.Endpoint != tt.want.Endpoint || cfg.Collection != tt.want.Collection || cfg.APIKey != tt.want.APIKey {
+				t.Errorf("LoadConfig() = %s %s %s, want %s %s %s",
+					cfg.Endpoint, cfg.Collection, cfg.APIKey,
+					tt.want.Endpoint, tt.want.Collection, tt.want.APIKey)
+			}
+		})
+	}
+}
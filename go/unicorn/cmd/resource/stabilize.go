@@ -0,0 +1,191 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+const (
+	// StatusStabilizing is the callback context status while a handler
+	// waits for its change to become visible in the backend.
+	StatusStabilizing = "stabilizing"
+
+	// baseCallbackDelay is the delay before the first reinvocation; it doubles
+	// with every attempt up to maxCallbackDelay.
+	baseCallbackDelay = 5
+	maxCallbackDelay  = 300
+)
+
+// stabilization is the state callback mode keeps in the callback context between invocations.
+type stabilization struct {
+	// Action is the handler action being stabilized: Create, Update or Delete.
+	Action string
+	// UID is the ID of the unicorn.
+	UID string
+	// Attempt is the number of times the backend has been polled.
+	Attempt int
+	// Started is when the change was made.
+	Started time.Time
+}
+
+// inCallbackMode reports whether the handlers are configured for callback mode.
+func inCallbackMode() bool {
+	cfg, err := readConfig()
+	return err == nil && cfg.Mode == ModeCallback
+}
+
+// confirmDelete reports whether Delete should wait until the backend
+// no longer returns the unicorn, even outside callback mode.
+func confirmDelete() bool {
+	cfg, err := readConfig()
+	return err == nil && cfg.ConfirmDelete
+}
+
+// stabilizationFrom reads the stabilization state from a callback context.
+// It returns false if the handler is not being reinvoked.
+func stabilizationFrom(ctx map[string]interface{}) (*stabilization, bool) {
+	if ctx["status"] != StatusStabilizing {
+		return nil, false
+	}
+	s := &stabilization{}
+	s.Action, _ = ctx["action"].(string)
+	s.UID, _ = ctx["uid"].(string)
+	// The callback context travels as JSON, so numbers come back as float64.
+	switch v := ctx["attempt"].(type) {
+	case float64:
+		s.Attempt = int(v)
+	case int:
+		s.Attempt = v
+	}
+	if v, ok := ctx["started"].(string); ok {
+		s.Started, _ = time.Parse(time.RFC3339, v)
+	}
+	return s, true
+}
+
+// context returns the callback context that carries s to the next invocation.
+func (s *stabilization) context() map[string]interface{} {
+	return map[string]interface{}{
+		"status":  StatusStabilizing,
+		"action":  s.Action,
+		"uid":     s.UID,
+		"attempt": s.Attempt,
+		"started": s.Started.UTC().Format(time.RFC3339),
+	}
+}
+
+// delay returns the exponential backoff before the next poll, in seconds.
+func (s *stabilization) delay() int64 {
+	d := baseCallbackDelay * math.Pow(2, float64(s.Attempt))
+	if d > maxCallbackDelay {
+		d = maxCallbackDelay
+	}
+	return int64(d)
+}
+
+// inProgress asks AWS CloudFormation to reinvoke the handler after the backoff delay.
+func (s *stabilization) inProgress(model *Model) handler.ProgressEvent {
+	return handler.ProgressEvent{
+		OperationStatus:      handler.InProgress,
+		Message:              s.Action + " in progress",
+		CallbackContext:      s.context(),
+		CallbackDelaySeconds: s.delay(),
+		ResourceModel:        model,
+	}
+}
+
+// stabilize polls the backend for the unicorn recorded in the callback context.
+// Create and Update are complete once the backend returns the desired unicorn,
+// Delete once the backend no longer finds it. Until then the handler is
+// reinvoked with a growing delay, and it fails with NotStabilized when
+// the configured timeout expires.
+func stabilize(req handler.Request, l *Logger, span *Span, s *stabilization, currentModel *Model) handler.ProgressEvent {
+	if s.UID == "" {
+		return handler.ProgressEvent{
+			OperationStatus:  handler.Failed,
+			HandlerErrorCode: cloudformation.HandlerErrorCodeInternalFailure,
+			Message:          "Callback context is missing the unicorn UID",
+		}
+	}
+	store, err := storeFor(req, l, span)
+	if err != nil {
+		return failed(err)
+	}
+
+	u, err := store.Get(s.UID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return failed(err)
+	}
+	switch s.Action {
+	case "Create", "Update":
+		if u != nil && (s.Action == "Create" || matches(u, currentModel)) {
+			return handler.ProgressEvent{
+				OperationStatus: handler.Success,
+				Message:         s.Action + " Complete",
+				ResourceModel:   unmarshal(u),
+			}
+		}
+	case "Delete":
+		if u == nil {
+			return handler.ProgressEvent{
+				OperationStatus: handler.Success,
+				Message:         "Delete Complete",
+			}
+		}
+	default:
+		return handler.ProgressEvent{
+			OperationStatus:  handler.Failed,
+			HandlerErrorCode: cloudformation.HandlerErrorCodeInternalFailure,
+			Message:          "Callback context has an unknown action: " + s.Action,
+		}
+	}
+
+	timeout := DefaultStabilizationTimeout
+	if cfg, err := readConfig(); err == nil {
+		timeout = cfg.Timeout()
+	}
+	if time.Since(s.Started) > timeout {
+		return handler.ProgressEvent{
+			OperationStatus:  handler.Failed,
+			HandlerErrorCode: cloudformation.HandlerErrorCodeNotStabilized,
+			Message:          fmt.Sprintf("%s of unicorn %s did not complete within %s", s.Action, s.UID, timeout),
+		}
+	}
+
+	s.Attempt++
+	var model *Model
+	if s.Action != "Delete" {
+		model = &Model{}
+		*model = *currentModel
+		model.UID = aws.String(s.UID)
+	}
+	return s.inProgress(model)
+}
+
+// matches reports whether the stored unicorn has the values of the model.
+// The unicorn goes through the model first so only the properties the
+// model can set are compared.
+func matches(u *Unicorn, model *Model) bool {
+	stored := marshal(unmarshal(u))
+	desired := marshal(model)
+	// Update keeps tags added outside of CloudFormation,
+	// so only the desired tags are compared.
+	var tags map[string]string
+	for k := range desired.Tags {
+		if v, ok := stored.Tags[k]; ok {
+			if tags == nil {
+				tags = map[string]string{}
+			}
+			tags[k] = v
+		}
+	}
+	stored.Tags = tags
+	return reflect.DeepEqual(stored, desired)
+}
@@ -0,0 +1,232 @@
+package resource
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// reinvoke returns the request CloudFormation makes after event, with
+// the callback context sent through JSON as it is between invocations.
+func reinvoke(t *testing.T, event handler.ProgressEvent) handler.Request {
+	t.Helper()
+	req := newRequest()
+	if err := json.Unmarshal([]byte(mustJSON(t, event.CallbackContext)), &req.CallbackContext); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// checkInProgress fails the test unless event asks to be reinvoked
+// after delay seconds to poll for the given attempt.
+func checkInProgress(t *testing.T, event handler.ProgressEvent, err error, action string, attempt int, delay int64) {
+	t.Helper()
+	checkEvent(t, event, err, handler.InProgress, "")
+	if event.CallbackContext["action"] != action || event.CallbackContext["attempt"] != attempt || event.CallbackDelaySeconds != delay {
+		t.Fatalf("callback context %v with delay %d, want %s attempt %d with delay %d", event.CallbackContext, event.CallbackDelaySeconds, action, attempt, delay)
+	}
+}
+
+func TestCallbackMode(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		invoke func(req handler.Request, seeded *Model) (handler.ProgressEvent, error)
+		want   *Model
+	}{
+		{
+			name:   "create",
+			action: "Create",
+			invoke: func(req handler.Request, _ *Model) (handler.ProgressEvent, error) {
+				return Create(req, &Model{}, newModel("Glitter", "Gold"))
+			},
+			want: newModel("Glitter", "Gold"),
+		},
+		{
+			name:   "update",
+			action: "Update",
+			invoke: func(req handler.Request, seeded *Model) (handler.ProgressEvent, error) {
+				current := *seeded
+				current.Color = aws.String("Silver")
+				return Update(req, seeded, &current)
+			},
+			want: newModel("Sparkles", "Silver"),
+		},
+		{
+			name:   "delete",
+			action: "Delete",
+			invoke: func(req handler.Request, seeded *Model) (handler.ProgressEvent, error) {
+				return Delete(req, &Model{}, seeded)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useMemoryStore(t)
+			seeded := mustCreate(t, newModel("Sparkles", "Pink"))
+			t.Setenv(EnvMode, ModeCallback)
+
+			event, err := tt.invoke(newRequest(), seeded)
+			checkInProgress(t, event, err, tt.action, 0, baseCallbackDelay)
+			event, err = tt.invoke(reinvoke(t, event), seeded)
+			checkEvent(t, event, err, handler.Success, "")
+
+			if tt.want == nil {
+				if n := len(store.unicorns); n != 0 {
+					t.Errorf("%d unicorns stored, want none", n)
+				}
+				return
+			}
+			got := event.ResourceModel.(*Model)
+			if aws.StringValue(got.Name) != aws.StringValue(tt.want.Name) || aws.StringValue(got.Color) != aws.StringValue(tt.want.Color) {
+				t.Errorf("model = %s %s, want %s %s", aws.StringValue(got.Name), aws.StringValue(got.Color), aws.StringValue(tt.want.Name), aws.StringValue(tt.want.Color))
+			}
+		})
+	}
+}
+
+func TestCallbackModeUpdateStabilizes(t *testing.T) {
+	store := useMemoryStore(t)
+	seeded := mustCreate(t, newModel("Sparkles", "Pink"))
+	t.Setenv(EnvMode, ModeCallback)
+	uid := aws.StringValue(seeded.UID)
+	current := *seeded
+	current.Color = aws.String("Silver")
+
+	event, err := Update(newRequest(), seeded, &current)
+	checkInProgress(t, event, err, "Update", 0, baseCallbackDelay)
+	updated, _ := store.Get(uid)
+
+	// The backend still returns the old unicorn: poll again, later.
+	stale, _ := store.Get(uid)
+	stale.Color = "Pink"
+	store.Put(uid, stale)
+	event, err = Update(reinvoke(t, event), seeded, &current)
+	checkInProgress(t, event, err, "Update", 1, 2*baseCallbackDelay)
+	event, err = Update(reinvoke(t, event), seeded, &current)
+	checkInProgress(t, event, err, "Update", 2, 4*baseCallbackDelay)
+
+	// Once it returns the update, the handler succeeds.
+	store.Put(uid, updated)
+	event, err = Update(reinvoke(t, event), seeded, &current)
+	checkEvent(t, event, err, handler.Success, "")
+}
+
+func TestCallbackModeNotStabilized(t *testing.T) {
+	useMemoryStore(t)
+	seeded := mustCreate(t, newModel("Sparkles", "Pink"))
+	t.Setenv(EnvMode, ModeCallback)
+	t.Setenv(EnvStabilizationTimeout, "60")
+	current := *seeded
+	current.Color = aws.String("Silver")
+
+	// The backend never returned the update within the timeout.
+	s := &stabilization{Action: "Update", UID: aws.StringValue(seeded.UID), Attempt: 4, Started: time.Now().Add(-2 * time.Minute)}
+	req := reinvoke(t, handler.ProgressEvent{CallbackContext: s.context()})
+	event, err := Update(req, seeded, &current)
+	checkEvent(t, event, err, handler.Failed, cloudformation.HandlerErrorCodeNotStabilized)
+}
+
+func TestStabilizationDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    int64
+	}{
+		{attempt: 0, want: 5},
+		{attempt: 1, want: 10},
+		{attempt: 5, want: 160},
+		{attempt: 6, want: maxCallbackDelay},
+		{attempt: 100, want: maxCallbackDelay},
+	}
+	for _, tt := range tests {
+		s := &stabilization{Attempt: tt.attempt}
+		if got := s.delay(); got != tt.want {
+			t.Errorf("delay() at attempt %d = %d, want %d", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestStabilizationFrom(t *testing.T) {
+	started := time.Date(2023, 2, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		ctx  map[string]interface{}
+		want *stabilization
+	}{
+		{name: "no context"},
+		{name: "other status", ctx: map[string]interface{}{"status": "done", "attempt": 1.0}},
+		{
+			name: "decoded from JSON",
+			ctx:  map[string]interface{}{"status": StatusStabilizing, "action": "Update", "uid": "1", "attempt": 3.0, "started": "2023-02-01T12:00:00Z"},
+			want: &stabilization{Action: "Update", UID: "1", Attempt: 3, Started: started},
+		},
+		{
+			name: "built in memory",
+			ctx:  (&stabilization{Action: "Delete", UID: "2", Attempt: 2, Started: started}).context(),
+			want: &stabilization{Action: "Delete", UID: "2", Attempt: 2, Started: started},
+		},
+		{
+			name: "missing attempt",
+			ctx:  map[string]interface{}{"status": StatusStabilizing, "action": "Create", "uid": "3"},
+			want: &stabilization{Action: "Create", UID: "3"},
+		},
+	}
+	for _, tt := range tests {
+		got, ok := stabilizationFrom(tt.ctx)
+		if ok != (tt.want != nil) {
+			t.Errorf("%s: stabilizationFrom() ok = %v, want %v", tt.name, ok, tt.want != nil)
+			continue
+		}
+		if ok && (*got != *tt.want) {
+			t.Errorf("%s: stabilizationFrom() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// lingeringStore keeps returning a deleted unicorn for a number of reads,
+// like a backend whose deletes take a while to become visible.
+type lingeringStore struct {
+	*MemoryStore
+	reads   int
+	deleted map[string]*Unicorn
+}
+
+func (s *lingeringStore) Get(id string) (*Unicorn, error) {
+	if u, ok := s.deleted[id]; ok && s.reads > 0 {
+		s.reads--
+		return u, nil
+	}
+	return s.MemoryStore.Get(id)
+}
+
+func (s *lingeringStore) Delete(id string) error {
+	u, err := s.MemoryStore.Get(id)
+	if err != nil {
+		return err
+	}
+	s.deleted[id] = u
+	return s.MemoryStore.Delete(id)
+}
+
+func TestConfirmDelete(t *testing.T) {
+	memory := useMemoryStore(t)
+	seeded := mustCreate(t, newModel("Sparkles", "Pink"))
+	Store = &lingeringStore{MemoryStore: memory, reads: 1, deleted: map[string]*Unicorn{}}
+	t.Setenv(EnvConfirmDelete, "true")
+
+	event, err := Delete(newRequest(), &Model{}, seeded)
+	checkInProgress(t, event, err, "Delete", 0, baseCallbackDelay)
+	// The backend still returns the unicorn.
+	event, err = Delete(reinvoke(t, event), &Model{}, seeded)
+	checkInProgress(t, event, err, "Delete", 1, 2*baseCallbackDelay)
+	// Now it returns NotFound.
+	event, err = Delete(reinvoke(t, event), &Model{}, seeded)
+	checkEvent(t, event, err, handler.Success, "")
+	if event.ResourceModel != nil {
+		t.Errorf("model = %v, want none after Delete", event.ResourceModel)
+	}
+}
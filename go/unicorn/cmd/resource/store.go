@@ -0,0 +1,84 @@
+package resource
+
+import (
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// A StoreError is an error reported by a UnicornStore.
+// Code is the HandlerErrorCode returned to CloudFormation.
+type StoreError struct {
+	Code    string
+	Message string
+}
+
+func (e *StoreError) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is a StoreError with the same Code,
+// so errors.Is(err, ErrNotFound) matches every NotFound error.
+func (e *StoreError) Is(target error) bool {
+	t, ok := target.(*StoreError)
+	return ok && t.Code == e.Code
+}
+
+// ErrNotFound is returned by a UnicornStore when the requested
+// unicorn does not exist.
+var ErrNotFound = &StoreError{
+	Code:    cloudformation.HandlerErrorCodeNotFound,
+	Message: "Resource not found",
+}
+
+// UnicornStore is the backend the resource handlers use to persist unicorns.
+// The crudcrud API is one implementation; MemoryStore is another.
+type UnicornStore interface {
+	// Create stores a new unicorn and returns it with its ID set.
+	Create(u *Unicorn) (*Unicorn, error)
+	// Get returns the unicorn with the given ID.
+	Get(id string) (*Unicorn, error)
+	// Put replaces the unicorn with the given ID.
+	Put(id string, u *Unicorn) error
+	// Delete removes the unicorn with the given ID.
+	Delete(id string) error
+	// List returns up to limit unicorns, starting where the token from
+	// a previous call left off, or from the beginning if token is empty.
+	// It also returns the token for the next page, empty on the last page.
+	List(token string, limit int) ([]Unicorn, string, error)
+}
+
+// A scanner is a UnicornStore that can return the whole collection in one
+// call. Searches use it instead of paging through List, which costs a
+// backend request per page.
+type scanner interface {
+	// scan returns every unicorn in the collection, in List order.
+	scan() ([]Unicorn, error)
+}
+
+// Store is the backend used by the handlers.
+// When nil, a CrudCrudStore is built from the runtime configuration.
+// Set it to point the handlers at a different backend,
+// for example a MemoryStore in unit tests.
+var Store UnicornStore
+
+// storeFor returns the backend to use for the request. The invocation
+// deadline is counted from the call, so handlers call it once, up front.
+// Backend requests are logged to l, traced as children of span and
+// measured in metricsOutput.
+func storeFor(req handler.Request, l *Logger, span *Span) (UnicornStore, error) {
+	if Store != nil {
+		return Store, nil
+	}
+	cfg, err := LoadConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	store := NewCrudCrudStore(cfg.URL())
+	store.Retry = cfg.RetryPolicy()
+	store.Deadline = cfg.Deadline()
+	store.Log = l
+	store.Action = l.action()
+	store.Metrics = metricsOutput
+	store.Span = span
+	return store, nil
+}
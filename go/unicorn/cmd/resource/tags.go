@@ -0,0 +1,119 @@
+package resource
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudformation"
+)
+
+// tagMap converts model tags to the map stored on the backend.
+// It returns nil when there are no tags.
+func tagMap(tags []Tag) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(tags))
+	for _, t := range tags {
+		m[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return m
+}
+
+// modelTags converts stored tags back to model tags, sorted by key
+// so Read and List return them in a stable order.
+func modelTags(m map[string]string) []Tag {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tags := make([]Tag, len(keys))
+	for i, k := range keys {
+		tags[i] = Tag{
+			Key:   aws.String(k),
+			Value: aws.String(m[k]),
+		}
+	}
+	return tags
+}
+
+// diffTags returns the tags that are new or changed in current,
+// and the keys of the tags that were removed from prev.
+func diffTags(prev, current map[string]string) (add map[string]string, remove []string) {
+	add = map[string]string{}
+	for k, v := range current {
+		if old, ok := prev[k]; !ok || old != v {
+			add[k] = v
+		}
+	}
+	for k := range prev {
+		if _, ok := current[k]; !ok {
+			remove = append(remove, k)
+		}
+	}
+	sort.Strings(remove)
+	return add, remove
+}
+
+// applyTags applies the tag changes between prevModel and currentModel to
+// the stored tags. Tags that were added to the unicorn outside of
+// CloudFormation are kept.
+func applyTags(stored map[string]string, prevModel *Model, currentModel *Model) map[string]string {
+	var prev map[string]string
+	if prevModel != nil {
+		prev = tagMap(prevModel.Tags)
+	}
+	add, remove := diffTags(prev, tagMap(currentModel.Tags))
+	tags := make(map[string]string, len(stored)+len(add))
+	for k, v := range stored {
+		tags[k] = v
+	}
+	for _, k := range remove {
+		delete(tags, k)
+	}
+	for k, v := range add {
+		tags[k] = v
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// stackTags returns the stack-level tags of the request. CloudFormation
+// sends every stack tag on every request, so a request without them means
+// the stack has none left. It returns nil when there are no tags.
+func stackTags(req handler.Request) map[string]string {
+	if len(req.RequestContext.StackTags) == 0 {
+		return nil
+	}
+	return req.RequestContext.StackTags
+}
+
+// validateTags rejects tag lists that use the same key twice,
+// which the schema cannot express.
+func validateTags(model *Model) *handler.ProgressEvent {
+	seen := map[string]bool{}
+	var dups []string
+	for _, t := range model.Tags {
+		k := aws.StringValue(t.Key)
+		if seen[k] {
+			dups = append(dups, k)
+		}
+		seen[k] = true
+	}
+	if len(dups) == 0 {
+		return nil
+	}
+	return &handler.ProgressEvent{
+		OperationStatus:  handler.Failed,
+		HandlerErrorCode: cloudformation.HandlerErrorCodeInvalidRequest,
+		Message:          "Invalid properties: Tags: duplicate keys " + strings.Join(dups, ", "),
+	}
+}
@@ -0,0 +1,243 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Trace exporters.
+const (
+	// TracingNone turns tracing off.
+	TracingNone = "none"
+	// TracingStdout writes every span to stdout as a line of JSON.
+	TracingStdout = "stdout"
+	// TracingOTLP sends every span to an OpenTelemetry collector over OTLP/HTTP.
+	TracingOTLP = "otlp"
+
+	// DefaultTracingEndpoint is the OTLP/HTTP traces endpoint of a local collector.
+	DefaultTracingEndpoint = "http://localhost:4318/v1/traces"
+	// TracingServiceName is the service.name of the exported traces.
+	TracingServiceName = "brianterry-unicorn-maker"
+	// flushTimeout bounds exporting the spans of an invocation when it returns.
+	flushTimeout = 5 * time.Second
+	// tracerName is the instrumentation scope of the spans.
+	tracerName = "github.com/brianterry/unicorn-maker/go/cmd/resource"
+)
+
+// traceOutput receives the spans of the stdout exporter.
+var traceOutput io.Writer = os.Stdout
+
+// traceWriter writes to whatever traceOutput is when the exporter writes,
+// so the cached exporter follows changes of traceOutput.
+type traceWriter struct{}
+
+func (traceWriter) Write(b []byte) (int, error) {
+	return traceOutput.Write(b)
+}
+
+// tracing holds the tracer provider across invocations of a warm Lambda, so
+// the exporter and its connections are reused. It is rebuilt when the
+// configured exporter changes.
+var tracing struct {
+	sync.Mutex
+	key      string
+	provider *sdktrace.TracerProvider
+}
+
+// tracerProvider returns the provider of the configured exporter,
+// or nil when tracing is off.
+func tracerProvider() *sdktrace.TracerProvider {
+	cfg, err := readConfig()
+	if err != nil {
+		return nil
+	}
+	var key string
+	switch cfg.Tracing {
+	case TracingStdout:
+		key = TracingStdout
+	case TracingOTLP:
+		key = TracingOTLP + " " + cfg.TracingEndpoint
+	}
+
+	tracing.Lock()
+	defer tracing.Unlock()
+	if tracing.key == key {
+		return tracing.provider
+	}
+	if tracing.provider != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+		defer cancel()
+		tracing.provider.Shutdown(ctx)
+	}
+	tracing.key, tracing.provider = key, nil
+	if key == "" {
+		return nil
+	}
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		otel.Handle(err)
+		return nil
+	}
+	tracing.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(sdkresource.NewSchemaless(attribute.String("service.name", TracingServiceName))),
+	)
+	return tracing.provider
+}
+
+// newExporter returns the span exporter cfg asks for.
+func newExporter(cfg *Config) (sdktrace.SpanExporter, error) {
+	if cfg.Tracing == TracingStdout {
+		return stdouttrace.New(stdouttrace.WithWriter(traceWriter{}))
+	}
+	u, err := url.Parse(cfg.TracingEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracing endpoint: %v", err)
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithURLPath(u.Path),
+	}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	return otlptracehttp.New(context.Background(), opts...)
+}
+
+// A Span is an OpenTelemetry span: a timed operation of a trace.
+// A nil Span records nothing, so callers need not check whether
+// tracing is on.
+type Span struct {
+	ctx  context.Context
+	span trace.Span
+	// provider is set on the root span, whose end flushes the trace.
+	provider *sdktrace.TracerProvider
+}
+
+// startTrace starts the root span of a handler invocation, named after the
+// action. It returns nil when no trace exporter is configured.
+func startTrace(req handler.Request, action string, model *Model) *Span {
+	provider := tracerProvider()
+	if provider == nil {
+		return nil
+	}
+	ctx, span := provider.Tracer(tracerName).Start(context.Background(), action, trace.WithSpanKind(trace.SpanKindServer))
+	s := &Span{ctx: ctx, span: span, provider: provider}
+	s.SetAttribute("cloudformation.action", action)
+	s.SetAttribute("cloudformation.logical_resource_id", req.LogicalResourceID)
+	s.SetAttribute("cloudformation.stack_id", req.RequestContext.StackID)
+	s.SetAttribute("cloud.region", req.RequestContext.Region)
+	if model != nil && model.UID != nil {
+		s.SetAttribute("unicorn.uid", *model.UID)
+	}
+	if st, ok := stabilizationFrom(req.CallbackContext); ok {
+		s.SetAttribute("cloudformation.callback_attempt", st.Attempt)
+		s.SetAttribute("unicorn.uid", st.UID)
+	}
+	return s
+}
+
+// startChild starts a span of the same trace with s as its parent.
+func (s *Span) startChild(name string, kind trace.SpanKind) *Span {
+	if s == nil {
+		return nil
+	}
+	ctx, span := s.span.TracerProvider().Tracer(tracerName).Start(s.ctx, name, trace.WithSpanKind(kind))
+	return &Span{ctx: ctx, span: span}
+}
+
+// SetAttribute records an attribute of the span. Strings are redacted.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	var kv attribute.KeyValue
+	switch v := loggable(value).(type) {
+	case string:
+		kv = attribute.String(key, v)
+	case int:
+		kv = attribute.Int(key, v)
+	case float64:
+		kv = attribute.Float64(key, v)
+	case bool:
+		kv = attribute.Bool(key, v)
+	default:
+		kv = attribute.String(key, fmt.Sprint(v))
+	}
+	s.span.SetAttributes(kv)
+}
+
+// SetStatus marks the span as successful, or as failed with message.
+func (s *Span) SetStatus(ok bool, message string) {
+	if s == nil {
+		return
+	}
+	if ok {
+		s.span.SetStatus(codes.Ok, "")
+		return
+	}
+	s.span.SetStatus(codes.Error, redact(message))
+}
+
+// End ends the span. Ending the root span exports the whole trace
+// before the Lambda is frozen.
+func (s *Span) End() error {
+	if s == nil {
+		return nil
+	}
+	s.span.End()
+	if s.provider == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	return s.provider.ForceFlush(ctx)
+}
+
+// inject adds the W3C Trace Context headers that make the receiver
+// of a request a child of s.
+func (s *Span) inject(h http.Header) {
+	if s == nil {
+		return
+	}
+	propagation.TraceContext{}.Inject(s.ctx, propagation.HeaderCarrier(h))
+}
+
+// endInvocation ends the root span of a handler invocation with the
+// outcome of the handler.
+func (s *Span) endInvocation(event *handler.ProgressEvent, err error) error {
+	if s == nil {
+		return nil
+	}
+	s.SetAttribute("cloudformation.operation_status", string(event.OperationStatus))
+	if m, ok := event.ResourceModel.(*Model); ok && m != nil && m.UID != nil {
+		s.SetAttribute("unicorn.uid", *m.UID)
+	}
+	switch {
+	case err != nil:
+		s.SetStatus(false, err.Error())
+	case event.OperationStatus == handler.Failed:
+		s.SetAttribute("cloudformation.error_code", event.HandlerErrorCode)
+		s.SetStatus(false, event.Message)
+	default:
+		s.SetStatus(true, "")
+	}
+	return s.End()
+}
@@ -0,0 +1,143 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws-cloudformation/cloudformation-cli-go-plugin/cfn/handler"
+	"github.com/brianterry/unicorn-maker/go/fakecrud"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// captureTraces collects the traces the stdout exporter writes during the test.
+func captureTraces(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	out := traceOutput
+	traceOutput = buf
+	t.Cleanup(func() { traceOutput = out })
+	return buf
+}
+
+// exportedSpan is what the stdout exporter writes of a span.
+type exportedSpan struct {
+	Name        string
+	SpanContext spanContext
+	Parent      spanContext
+	SpanKind    trace.SpanKind
+	Attributes  attributes
+	Status      struct{ Code string }
+	Resource    attributes
+}
+
+// spanContext holds the hex encoded IDs of an exported span.
+type spanContext struct {
+	TraceID, SpanID string
+}
+
+// attributes are exported attributes with their typed value.
+type attributes []struct {
+	Key   string
+	Value struct{ Value interface{} }
+}
+
+// attribute returns the string value of the attribute key, or "".
+func (a attributes) attribute(key string) string {
+	for _, kv := range a {
+		if s, ok := kv.Value.Value.(string); kv.Key == key && ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func (s exportedSpan) attribute(key string) string {
+	return s.Attributes.attribute(key)
+}
+
+var traceparentPattern = regexp.MustCompile(`^00-([0-9a-f]{32})-([0-9a-f]{16})-01$`)
+
+func TestTraceCreate(t *testing.T) {
+	const apiKey = "0123456789abcdef0123456789abcdef"
+	isolate(t)
+	captureMetrics(t)
+	traces := captureTraces(t)
+
+	var (
+		mu           sync.Mutex
+		traceparents []string
+	)
+	backend := fakecrud.NewServer()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		mu.Unlock()
+		backend.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	t.Setenv(EnvEndpoint, srv.URL)
+	t.Setenv(EnvAPIKey, apiKey)
+	t.Setenv(EnvTracing, TracingStdout)
+
+	event, err := Create(newRequest(), &Model{}, newModel("Sparkles", "Pink"))
+	checkEvent(t, event, err, handler.Success, "")
+
+	// The stdout exporter writes one line per span.
+	raw := traces.String()
+	var spans []exportedSpan
+	dec := json.NewDecoder(strings.NewReader(raw))
+	for dec.More() {
+		var span exportedSpan
+		if err := dec.Decode(&span); err != nil {
+			t.Fatalf("trace: %v", err)
+		}
+		spans = append(spans, span)
+	}
+	if len(spans) == 0 {
+		t.Fatal("no spans exported")
+	}
+
+	// The root span is exported last, when the handler returns.
+	root := spans[len(spans)-1]
+	if root.Name != "Create" || root.SpanKind != trace.SpanKindServer || root.Parent.SpanID != "0000000000000000" || root.Status.Code != "Ok" {
+		t.Errorf("root span = %+v, want an OK server span named Create without parent", root)
+	}
+	if root.Resource.attribute("service.name") != TracingServiceName {
+		t.Errorf("resource = %+v, want service.name %s", root.Resource, TracingServiceName)
+	}
+	children := spans[:len(spans)-1]
+	// findCreated scans the collection, then Create POSTs the unicorn.
+	if len(children) != 2 || children[0].Name != "GET" || children[1].Name != "POST" {
+		t.Fatalf("child spans = %+v, want GET and POST", children)
+	}
+	if len(traceparents) != len(children) {
+		t.Fatalf("backend got %d requests, want %d", len(traceparents), len(children))
+	}
+	for i, child := range children {
+		if child.SpanContext.TraceID != root.SpanContext.TraceID || child.Parent.SpanID != root.SpanContext.SpanID || child.SpanKind != trace.SpanKindClient {
+			t.Errorf("child span %s = %+v, want a client span of trace %s with parent %s", child.Name, child, root.SpanContext.TraceID, root.SpanContext.SpanID)
+		}
+		// The backend sees the request span as its parent.
+		m := traceparentPattern.FindStringSubmatch(traceparents[i])
+		if m == nil {
+			t.Errorf("traceparent = %q, want 00-<trace>-<span>-01", traceparents[i])
+			continue
+		}
+		if m[1] != child.SpanContext.TraceID || m[2] != child.SpanContext.SpanID {
+			t.Errorf("traceparent = %q, want trace %s and span %s", traceparents[i], child.SpanContext.TraceID, child.SpanContext.SpanID)
+		}
+		url := child.attribute("url.full")
+		if !strings.HasPrefix(url, srv.URL) || !strings.Contains(url, redacted) || strings.Contains(url, apiKey) {
+			t.Errorf("url.full = %q, want the URL with the API key redacted", url)
+		}
+	}
+	if strings.Contains(raw, apiKey) {
+		t.Error("trace contains the API key")
+	}
+}
@@ -0,0 +1,265 @@
+// Package fakecrud is a local stand-in for the crudcrud REST API.
+//
+// It implements the subset of crudcrud the resource handlers use:
+//
+//	POST   /api/<key>/<collection>       creates a document and returns it with its _id
+//	GET    /api/<key>/<collection>       returns every document in the collection
+//	GET    /api/<key>/<collection>/<id>  returns a single document
+//	PUT    /api/<key>/<collection>/<id>  replaces a document, ignoring any _id in the body
+//	DELETE /api/<key>/<collection>/<id>  removes a document
+//
+// Unknown documents are reported with a 404. The /api prefix is optional.
+// Documents are kept in memory and, when a file is given, saved to it as JSON.
+package fakecrud
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A Document is a single crudcrud record.
+type Document map[string]interface{}
+
+// Server is an http.Handler that behaves like crudcrud.
+// It is safe for concurrent use.
+type Server struct {
+	mu   sync.Mutex
+	path string
+	// collections maps "<key>/<collection>" to the documents in it, in insertion order.
+	collections map[string][]Document
+}
+
+// NewServer returns an empty, memory-only Server.
+func NewServer() *Server {
+	return &Server{
+		collections: map[string][]Document{},
+	}
+}
+
+// NewFileServer returns a Server that loads its documents from path,
+// if it exists, and saves them back after every change.
+func NewFileServer(path string) (*Server, error) {
+	s := NewServer()
+	s.path = path
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(b, &s.collections); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(strings.Trim(r.URL.Path, "/"), "api/")
+	parts := strings.Split(p, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	collection := parts[0] + "/" + parts[1]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(parts) == 2 {
+		switch r.Method {
+		case http.MethodGet:
+			s.list(w, collection)
+		case http.MethodPost:
+			s.create(w, r, collection)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id := parts[2]
+	switch r.Method {
+	case http.MethodGet:
+		s.get(w, r, collection, id)
+	case http.MethodPut:
+		s.put(w, r, collection, id)
+	case http.MethodDelete:
+		s.delete(w, r, collection, id)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, collection string) {
+	docs := s.collections[collection]
+	if docs == nil {
+		docs = []Document{}
+	}
+	writeJSON(w, http.StatusOK, docs)
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, collection string) {
+	doc, ok := readDocument(w, r)
+	if !ok {
+		return
+	}
+	id, err := newID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	doc["_id"] = id
+	docs := append(s.copyOf(collection), doc)
+	if !s.commit(w, collection, docs) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, doc)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, collection, id string) {
+	i := s.find(collection, id)
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.collections[collection][i])
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, collection, id string) {
+	i := s.find(collection, id)
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	doc, ok := readDocument(w, r)
+	if !ok {
+		return
+	}
+	doc["_id"] = id
+	docs := s.copyOf(collection)
+	docs[i] = doc
+	if !s.commit(w, collection, docs) {
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, collection, id string) {
+	i := s.find(collection, id)
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	docs := s.copyOf(collection)
+	docs = append(docs[:i], docs[i+1:]...)
+	if !s.commit(w, collection, docs) {
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// find returns the index of the document with the given id, or -1.
+func (s *Server) find(collection, id string) int {
+	for i, doc := range s.collections[collection] {
+		if doc["_id"] == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// copyOf returns a copy of the documents of collection that can be
+// changed without touching the stored ones.
+func (s *Server) copyOf(collection string) []Document {
+	return append([]Document(nil), s.collections[collection]...)
+}
+
+// commit replaces the documents of collection with docs and saves them.
+// If saving fails, the change is rolled back, so memory and the file
+// stay in step, and it reports a 500 and returns false.
+func (s *Server) commit(w http.ResponseWriter, collection string, docs []Document) bool {
+	old, ok := s.collections[collection]
+	s.collections[collection] = docs
+	if s.save(w) {
+		return true
+	}
+	if ok {
+		s.collections[collection] = old
+	} else {
+		delete(s.collections, collection)
+	}
+	return false
+}
+
+// save writes the documents to the backing file, if there is one.
+// It reports a 500 and returns false on failure.
+func (s *Server) save(w http.ResponseWriter) bool {
+	if s.path == "" {
+		return true
+	}
+	b, err := json.MarshalIndent(s.collections, "", "    ")
+	if err == nil {
+		err = writeFile(s.path, b)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// writeFile replaces the file at path so readers never see a partial write.
+func writeFile(path string, b []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readDocument decodes the request body, reporting a 400 if it is not a JSON object.
+func readDocument(w http.ResponseWriter, r *http.Request) (Document, bool) {
+	doc := Document{}
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil || doc == nil {
+		http.Error(w, "request body must be a JSON object", http.StatusBadRequest)
+		return nil, false
+	}
+	delete(doc, "_id")
+	return doc, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// newID returns a random 24 character hex ID, the same shape crudcrud uses.
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
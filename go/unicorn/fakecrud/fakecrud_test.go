@@ -0,0 +1,171 @@
+package fakecrud_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brianterry/unicorn-maker/go/cmd/resource"
+	"github.com/brianterry/unicorn-maker/go/fakecrud"
+)
+
+// newStore returns a CrudCrudStore for a collection of srv.
+func newStore(srv *httptest.Server) *resource.CrudCrudStore {
+	store := resource.NewCrudCrudStore(srv.URL + "/api/key/unicorns")
+	store.Retry.MaxRetries = 0
+	return store
+}
+
+func TestCrudCrudStore(t *testing.T) {
+	srv := httptest.NewServer(fakecrud.NewServer())
+	defer srv.Close()
+	store := newStore(srv)
+
+	// POST
+	created, err := store.Create(&resource.Unicorn{Name: "Sparkles", Color: "Pink"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if len(created.ID) != 24 || created.Name != "Sparkles" {
+		t.Fatalf("Create() = %+v, want a 24 character ID and the name", created)
+	}
+	if _, err := store.Create(&resource.Unicorn{Name: "Glitter", Color: "Gold"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// GET
+	got, err := store.Get(created.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.ID != created.ID || got.Color != "Pink" {
+		t.Errorf("Get() = %+v, want %+v", got, created)
+	}
+
+	// List
+	unicorns, next, err := store.List("", 10)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(unicorns) != 2 || next != "" || unicorns[0].Name != "Sparkles" || unicorns[1].Name != "Glitter" {
+		t.Errorf("List() = %+v, %q, want Sparkles and Glitter on one page", unicorns, next)
+	}
+
+	// PUT, with the ID kept from the URL.
+	if err := store.Put(created.ID, &resource.Unicorn{ID: "ignored", Name: "Sparkles", Color: "Silver"}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	got, err = store.Get(created.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.ID != created.ID || got.Color != "Silver" {
+		t.Errorf("Get() after Put = %+v, want color Silver", got)
+	}
+
+	// DELETE
+	if err := store.Delete(created.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	unicorns, _, err = store.List("", 10)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(unicorns) != 1 || unicorns[0].Name != "Glitter" {
+		t.Errorf("List() after Delete = %+v, want only Glitter", unicorns)
+	}
+
+	// 404s
+	if _, err := store.Get(created.ID); !errors.Is(err, resource.ErrNotFound) {
+		t.Errorf("Get() of a deleted unicorn error = %v, want ErrNotFound", err)
+	}
+	if err := store.Put(created.ID, &resource.Unicorn{Name: "Sparkles"}); !errors.Is(err, resource.ErrNotFound) {
+		t.Errorf("Put() of a deleted unicorn error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(created.ID); !errors.Is(err, resource.ErrNotFound) {
+		t.Errorf("Delete() of a deleted unicorn error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCrudCrudStoreEmptyCollection(t *testing.T) {
+	srv := httptest.NewServer(fakecrud.NewServer())
+	defer srv.Close()
+
+	unicorns, next, err := newStore(srv).List("", 10)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(unicorns) != 0 || next != "" {
+		t.Errorf("List() = %+v, %q, want no unicorns", unicorns, next)
+	}
+}
+
+func TestFileServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fakecrud.json")
+	s, err := fakecrud.NewFileServer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	created, err := newStore(srv).Create(&resource.Unicorn{Name: "Sparkles", Color: "Pink"})
+	srv.Close()
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// A new server picks up the saved documents.
+	s, err = fakecrud.NewFileServer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv = httptest.NewServer(s)
+	defer srv.Close()
+	got, err := newStore(srv).Get(created.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Name != "Sparkles" {
+		t.Errorf("Get() = %+v, want Sparkles", got)
+	}
+}
+
+func TestFileServerSaveFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	s, err := fakecrud.NewFileServer(filepath.Join(dir, "fakecrud.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	store := newStore(srv)
+	created, err := store.Create(&resource.Unicorn{Name: "Sparkles", Color: "Pink"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// Every later save fails; the documents in memory must not change.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(&resource.Unicorn{Name: "Glitter", Color: "Gold"}); err == nil {
+		t.Error("Create() succeeded, want an error")
+	}
+	if err := store.Put(created.ID, &resource.Unicorn{Name: "Sparkles", Color: "Silver"}); err == nil {
+		t.Error("Put() succeeded, want an error")
+	}
+	if err := store.Delete(created.ID); err == nil {
+		t.Error("Delete() succeeded, want an error")
+	}
+	unicorns, _, err := store.List("", 10)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(unicorns) != 1 || unicorns[0].ID != created.ID || unicorns[0].Color != "Pink" {
+		t.Errorf("List() = %+v, want only the unchanged Sparkles", unicorns)
+	}
+}
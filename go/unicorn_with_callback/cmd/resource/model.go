@@ -1,10 +0,0 @@
-// Code generated by 'cfn generate', changes will be undone by the next invocation. DO NOT EDIT.
-// Updates to this type are made my editing the schema file and executing the 'generate' command.
-package resource
-
-// Model is autogenerated from the json schema
-type Model struct {
-	UID   *string `json:",omitempty"`
-	Name  *string `json:",omitempty"`
-	Color *string `json:",omitempty"`
-}
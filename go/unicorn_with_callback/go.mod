@@ -1,8 +0,0 @@
-module github.com/brianterry/unicorn-maker/go
-
-go 1.13
-
-require (
-	github.com/aws-cloudformation/cloudformation-cli-go-plugin v1.0.3
-	github.com/aws/aws-sdk-go v1.25.37
-)